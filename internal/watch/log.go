@@ -0,0 +1,97 @@
+// Copyright 2024 Matthew P. Dargan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package watch
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Log records the files a watcher processed, so that they are not processed
+// again after a restart. Each line of the file holds the time a file was
+// processed, its size, and its path, separated by tabs. A file is identified
+// by its path and size, so a file rewritten with a different size is
+// processed again.
+type Log struct {
+	mu   sync.Mutex
+	f    *os.File
+	done map[string]bool
+}
+
+// OpenLog opens the log at path, creating it and its directory if needed.
+func OpenLog(path string) (*Log, error) {
+	done, err := readLog(path)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &Log{f: f, done: done}, nil
+}
+
+// readLog returns the files recorded in the log at path.
+func readLog(path string) (map[string]bool, error) {
+	done := make(map[string]bool)
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return done, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.SplitN(sc.Text(), "\t", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		size, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		done[logKey(fields[2], size)] = true
+	}
+	return done, sc.Err()
+}
+
+// logKey identifies a file by its path and size.
+func logKey(path string, size int64) string {
+	return fmt.Sprintf("%d\t%s", size, path)
+}
+
+// Done reports whether the file at path with the given size was recorded.
+func (l *Log) Done(path string, size int64) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.done[logKey(path, size)]
+}
+
+// Add records the file at path with the given size.
+func (l *Log) Add(path string, size int64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.done[logKey(path, size)] = true
+	_, err := fmt.Fprintf(l.f, "%s\t%d\t%s\n", time.Now().Format(time.RFC3339), size, path)
+	return err
+}
+
+// Close closes the log.
+func (l *Log) Close() error {
+	return l.f.Close()
+}
@@ -0,0 +1,89 @@
+// Copyright 2024 Matthew P. Dargan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package watch
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const inotifyMask = syscall.IN_CLOSE_WRITE | syscall.IN_CREATE | syscall.IN_MODIFY | syscall.IN_MOVED_TO
+
+// notify sends the paths of files and directories created, written, or moved
+// into dirs and their subdirectories to events until ctx is done.
+func notify(ctx context.Context, dirs []string, _ time.Duration, events chan<- string) error {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return os.NewSyscallError("inotify_init1", err)
+	}
+	f := os.NewFile(uintptr(fd), "inotify")
+	wds := make(map[int32]string)
+	add := func(dir string) error {
+		return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || !d.IsDir() {
+				return err
+			}
+			wd, err := syscall.InotifyAddWatch(fd, p, inotifyMask)
+			if err != nil {
+				return os.NewSyscallError("inotify_add_watch", err)
+			}
+			wds[int32(wd)] = p
+			return nil
+		})
+	}
+	for _, dir := range dirs {
+		if err = add(dir); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	go func() {
+		<-ctx.Done()
+		f.Close()
+	}()
+	go func() {
+		buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+		for {
+			n, err := f.Read(buf)
+			if err != nil {
+				return
+			}
+			for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+				ev := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[off]))
+				name := buf[off+syscall.SizeofInotifyEvent : off+syscall.SizeofInotifyEvent+int(ev.Len)]
+				off += syscall.SizeofInotifyEvent + int(ev.Len)
+				dir, ok := wds[ev.Wd]
+				if !ok || len(name) == 0 {
+					continue
+				}
+				p := filepath.Join(dir, string(name[:clen(name)]))
+				if ev.Mask&syscall.IN_ISDIR != 0 {
+					_ = add(p)
+				}
+				select {
+				case events <- p:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// clen returns the index of the first NUL byte in b, or len(b).
+func clen(b []byte) int {
+	for i, c := range b {
+		if c == 0 {
+			return i
+		}
+	}
+	return len(b)
+}
@@ -0,0 +1,36 @@
+// Copyright 2024 Matthew P. Dargan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+
+package watch
+
+import (
+	"context"
+	"time"
+)
+
+// notify sends dirs to events every settle interval until ctx is done, so
+// that new files are found by walking dirs.
+func notify(ctx context.Context, dirs []string, settle time.Duration, events chan<- string) error {
+	go func() {
+		tick := time.NewTicker(max(settle/2, time.Second))
+		defer tick.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick.C:
+			}
+			for _, dir := range dirs {
+				select {
+				case events <- dir:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return nil
+}
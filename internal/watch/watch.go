@@ -0,0 +1,99 @@
+// Copyright 2024 Matthew P. Dargan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package watch reports files in directories once they finish being written.
+package watch
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// partialExts are the extensions download clients give files they are still
+// writing.
+var partialExts = []string{
+	".!qb", ".!ut", ".aria2", ".crdownload", ".download", ".part", ".partial", ".tmp",
+}
+
+// Partial reports whether the file at path is a partial download.
+func Partial(path string) bool {
+	return slices.Contains(partialExts, strings.ToLower(filepath.Ext(path)))
+}
+
+// A file is the state of a file being watched.
+type file struct {
+	size    int64
+	mod     time.Time
+	changed time.Time // when size or mod last changed
+	done    bool      // whether the file was reported
+}
+
+// Watch calls f with the path of each file in dirs and their subdirectories
+// once its size and modification time have not changed for settle. Files
+// already in dirs are reported too. Partial downloads are skipped. A file is
+// reported again if it changes after it was reported. Watch returns when ctx
+// is done.
+func Watch(ctx context.Context, dirs []string, settle time.Duration, f func(path string)) error {
+	if len(dirs) == 0 {
+		return errors.New("no directories to watch")
+	}
+	events := make(chan string, 64)
+	if err := notify(ctx, dirs, settle, events); err != nil {
+		return err
+	}
+	files := make(map[string]*file)
+	for _, dir := range dirs {
+		track(files, dir)
+	}
+	tick := time.NewTicker(min(settle/4+time.Millisecond, time.Second))
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case p := <-events:
+			track(files, p)
+		case now := <-tick.C:
+			for p, fi := range files {
+				info, err := os.Stat(p)
+				if err != nil {
+					delete(files, p)
+					continue
+				}
+				if info.Size() != fi.size || !info.ModTime().Equal(fi.mod) {
+					*fi = file{size: info.Size(), mod: info.ModTime(), changed: now}
+					continue
+				}
+				if !fi.done && now.Sub(fi.changed) >= settle {
+					fi.done = true
+					f(p)
+				}
+			}
+		}
+	}
+}
+
+// track records the files at path, walking it if it is a directory.
+func track(files map[string]*file, path string) {
+	_ = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() || Partial(p) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		fi, ok := files[p]
+		if !ok || info.Size() != fi.size || !info.ModTime().Equal(fi.mod) {
+			files[p] = &file{size: info.Size(), mod: info.ModTime(), changed: time.Now()}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,124 @@
+// Copyright 2024 Matthew P. Dargan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package watch_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/matthewdargan/epify/internal/watch"
+)
+
+func TestPartial(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{path: "ep01.mkv", want: false},
+		{path: "ep01.mkv.part", want: true},
+		{path: "ep01.mkv.!qB", want: true},
+		{path: "ep01.mkv.crdownload", want: true},
+		{path: "ep01.srt", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			t.Parallel()
+			if got := watch.Partial(tt.path); got != tt.want {
+				t.Errorf("Partial(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWatch(t *testing.T) {
+	t.Parallel()
+	dir, err := os.MkdirTemp("", "watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	existing := filepath.Join(dir, "existing.mkv")
+	if err = os.WriteFile(existing, []byte("existing"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	paths := make(chan string, 8)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- watch.Watch(ctx, []string{dir}, 200*time.Millisecond, func(path string) { paths <- path })
+	}()
+	want := map[string]bool{existing: true}
+	if got := <-paths; got != existing {
+		t.Errorf("Watch() = %v, want %v", got, existing)
+	}
+	sub := filepath.Join(dir, "release")
+	if err = os.Mkdir(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	part := filepath.Join(sub, "ep01.mkv.part")
+	if err = os.WriteFile(part, []byte("ep"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ep := filepath.Join(sub, "ep01.mkv")
+	if err = os.Rename(part, ep); err != nil {
+		t.Fatal(err)
+	}
+	want[ep] = true
+	select {
+	case got := <-paths:
+		if !want[got] || got == existing {
+			t.Errorf("Watch() = %v, want %v", got, ep)
+		}
+	case <-ctx.Done():
+		t.Fatalf("Watch() did not report %v", ep)
+	}
+	cancel()
+	if err = <-errc; err != nil {
+		t.Errorf("Watch() error = %v", err)
+	}
+	close(paths)
+	for p := range paths {
+		t.Errorf("Watch() = %v, want no more files", p)
+	}
+}
+
+func TestLog(t *testing.T) {
+	t.Parallel()
+	dir, err := os.MkdirTemp("", "log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state", "watch.log")
+	l, err := watch.OpenLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Done("/downloads/ep1.mkv", 10) {
+		t.Error("Done(ep1.mkv) = true before Add")
+	}
+	if err = l.Add("/downloads/ep1.mkv", 10); err != nil {
+		t.Fatal(err)
+	}
+	if err = l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if l, err = watch.OpenLog(path); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if !l.Done("/downloads/ep1.mkv", 10) {
+		t.Error("Done(ep1.mkv) = false after reopening log")
+	}
+	if l.Done("/downloads/ep1.mkv", 20) {
+		t.Error("Done(ep1.mkv) = true for different size")
+	}
+}
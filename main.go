@@ -6,25 +6,164 @@
 //
 // Usage:
 //
-//	epify show name year tvdbid dir
-//	epify movie name year tmdbid dir movie
-//	epify season [-m index] seasonnum showdir episode...
-//	epify add [-m index] seasondir episode...
+//	epify show [-n] [-f profile] name [year id] dir
+//	epify movie [-n] [-c policy] [-f profile] [-p mode] name [year id] dir movie
+//	epify season [-k] [-n] [-s] [-c policy] [-f profile] [-j jobs] [-m index] [-p mode] [-re regexp] seasonnum showdir episode...
+//	epify add [-g] [-k] [-n] [-c policy] [-f profile] [-j jobs] [-m index] [-p mode] [-re regexp] seasondir episode...
+//	epify import [-n] [-s] [-c policy] [-f profile] [-j jobs] [-p mode] library path...
+//	epify watch [-s] [-c policy] [-d duration] [-f profile] [-j jobs] [-l log] [-p mode] library dir...
+//	epify check [-json] library...
+//	epify gaps [-json] showdir...
+//	epify undo [-n] [-l] [id]
+//	epify cache warm library...
+//	epify cache ls
+//	epify cache clear
 //
 // `epify show` creates a show directory like
-// "Series Name (2018) [tvdbid-65567]".
+// "Series Name (2018) [tvdbid-65567]", or "Series Name [tvdbid-65567]" if the
+// year is empty.
 //
 // `epify movie` adds a movie to a directory. Movies are labeled like
 // "Film (2018) [tmdbid-65567]".
 //
+// The ID is a TVDB ID for `epify show` and a TMDB ID for `epify movie`, or
+// comma-separated tags for any of TVDB, TMDB, and IMDb, like
+// "imdbid-tt0112573,tmdbid-197", which label the movie like
+// "Film (2018) [imdbid-tt0112573] [tmdbid-197]".
+//
+// If the year and ID are omitted, `epify show` searches TVDB for the show and
+// `epify movie` searches TMDB for the movie. The API keys are read from
+// $TVDB_API_KEY, with an optional subscriber PIN in $TVDB_PIN, and
+// $TMDB_API_KEY. $TVDB_URL and $TMDB_URL override the API base URLs.
+// Search results and episode lists are cached in $EPIFY_CACHE, or
+// $XDG_CACHE_HOME/epify if unset, so repeated lookups need no network access.
+// Without an API key, lookups are answered from the cache alone.
+//
 // `epify season` populates a season directory with episodes. Episodes are
-// labeled like "Series Name S01E01.mkv".
+// labeled like "Series Name S01E01.mkv". Subtitle, audio, and metadata files
+// named after an episode, like "ep01.en.srt", follow the episode and keep their
+// suffixes, like "Series Name S01E01.en.srt". Files holding multiple episodes,
+// like "ep01-02.mkv" or "S01E01E02.mkv", are labeled like
+// "Series Name S01E01-E02.mkv".
+//
+// Season 0 holds specials. The `-s` flag labels its directory "Specials"
+// instead of "Season 00".
+//
+// `epify add` adds episodes to a season directory, continuing after the
+// highest episode in it. Videos not labeled like episodes, like samples,
+// subtitles, metadata, artwork, and directories like "Extras" in the season
+// directory are ignored. The `-g` flag numbers episodes into the gaps before
+// the highest episode first. The season directory may be labeled like
+// "Season 01" or "Specials".
+//
+// `epify import` adds downloaded episodes to the show directories in a
+// library. It reads the show, season, and episode numbers from release names
+// like "Show.Name.S03E07.1080p.WEB.mkv" or "Show Name - 3x07.mkv", searching
+// directories for video files. New season directories are populated like
+// `epify season` and existing ones are added to like `epify add`, keeping the
+// episode numbers in the release names.
+//
+// `epify watch` imports video files into a library like `epify import` as
+// they finish downloading into the given directories. Files are imported once
+// they stop changing for the duration given by the `-d` flag, 30 seconds by
+// default. Partial downloads, like "ep01.mkv.part", are skipped. Imported
+// files are recorded in a log, given by the `-l` flag or
+// $XDG_STATE_HOME/epify/watch.log if unset, so they are not imported again.
+//
+// `epify check` reports the paths in show libraries that break the naming
+// epify produces: show directories without a TVDB, TMDB, or IMDb ID, season
+// directories not labeled like "Season 01", episodes whose season and episode
+// numbers do not match their season directory, and stray files that are not
+// videos, subtitles, or artwork. The `-json` flag prints the problems as JSON.
+// It exits with status 1 if it finds problems.
+//
+// `epify gaps` reports the episodes missing from and duplicated in the season
+// directories of show directories, like "Season 01: missing E06, E09-E10". For
+// shows labeled with a TVDB ID whose episodes are cached or can be fetched
+// from TVDB, episodes that aired after the last episode in a season directory
+// are reported missing too. The `-json` flag prints the reports as JSON. It
+// exits with status 1 if it finds missing or duplicate episodes.
+//
+// `epify undo` reverses the most recent command, or the command with the given
+// journal id, moving episodes and movies back to their original paths and
+// removing the directories the command created. Every command that modifies
+// the filesystem is recorded in a journal at $EPIFY_JOURNAL, or
+// $XDG_STATE_HOME/epify/journal.jsonl if unset. The `-l` flag lists the
+// journal instead.
+//
+// `epify cache warm` fetches into the cache the shows and movies labeled with
+// a TVDB or TMDB ID in the given library directories, along with the episodes
+// of each show. `epify cache ls` lists the cached shows and movies, and
+// `epify cache clear` empties the cache.
+//
+// The `-m` flag specifies the index of the episode number in filenames for the
+// `epify season` and `epify add` commands. Numbers in show names and
+// resolutions like "1080p" can shift the index from file to file, so the `-re`
+// flag instead reads episode numbers with a regular expression whose named
+// groups `(?P<episode>...)`, `(?P<season>...)`, and `(?P<part>...)` capture the
+// episode number, the season number, and the part of an episode split across
+// files. Episodes from a season other than the one being labeled are rejected,
+// and parts of an episode share its number, like "Show S01E05-part1.mkv" and
+// "Show S01E05-part2.mkv". The `-re` flag also accepts the presets `sxxeyy`
+// for names like "Show.S01E05.mkv", `nxnn` for names like "Show 1x05.mkv", and
+// `episode` for names like "Show Episode 5.mkv", and it overrides the `-m`
+// flag.
+//
+// The `-k` flag keeps the episode numbers at the match index, or read by the
+// `-re` flag, for the `epify season` and `epify add` commands instead of
+// numbering episodes in order.
 //
-// `epify add` adds episodes to a season directory, continuing at the previous
-// episode increment.
+// The `-n` flag prints the directories each command would create and the files
+// it would rename without modifying the filesystem.
 //
-// The `-m` flag specifies the index of the episode number in filenames for
-// the `epify season` and `epify add` commands.
+// The `-p` flag specifies how the `epify movie`, `epify season`, and
+// `epify add` commands place files in the library: `rename` (the default),
+// `link` for hard links, `symlink`, `reflink` for copy-on-write clones, or
+// `copy`. Every mode except `rename` leaves the original files in place.
+//
+// Existing files in the library are never overwritten. The `-c` flag
+// specifies what the `epify movie`, `epify season`, `epify add`,
+// `epify import`, and `epify watch` commands do when a file would be placed
+// at a path that exists: `fail` (the default) refuses to run the command,
+// `skip` keeps the existing file, `suffix` keeps both by labeling the new
+// file like "Series Name S01E01 (1).mkv", and `replace-larger` replaces the
+// existing file only if the new one is of better quality, or, if their
+// qualities are the same or unknown, larger. Quality is the resolution, read
+// from tags like "1080p" in release names or from the headers of Matroska and
+// MP4 files, and then the source, like "BluRay". A replaced file is kept
+// beside the new one as a hidden backup, like
+// ".Series Name S01E01.mkv.epify-backup-0", so that `epify undo` restores it.
+//
+// The `-f` flag specifies the file name rules of the filesystem holding the
+// library: `posix` (the default), `windows` for NTFS, or `smb` for Samba shares.
+// Show and movie names are sanitized before they are put in paths, so
+// "Face/Off" becomes "Face-Off", and with `windows` or `smb`,
+// "Mission: Impossible" becomes "Mission - Impossible". Characters the
+// filesystem forbids are replaced or removed, trailing dots and spaces are
+// trimmed, and long names are shortened to fit the filesystem's length limit.
+//
+// Files renamed across filesystems are copied, verified, and then removed
+// from their original location.
+//
+// Every command that modifies the filesystem checks all of its renames before
+// making any of them. If one fails partway through, the files already moved are
+// moved back and the directories the command created are removed.
+//
+// The `-j` flag specifies how many files the `epify season`, `epify add`,
+// `epify import`, and `epify watch` commands place at once, 4 by default.
+// Lowering it keeps copies across slow network filesystems from saturating
+// them.
+//
+// An interrupt or SIGTERM stops a command cleanly: copies in progress are
+// abandoned, no further files are placed, and the operations already
+// completed are kept, recorded in the journal, and listed, so that
+// `epify undo` can reverse them.
+//
+// If $JELLYFIN_URL is defined, every command that modifies the filesystem
+// asks the Jellyfin server at that URL to scan the paths it changed, so new
+// episodes and movies show up without waiting for a scheduled library scan.
+// The server's API key is read from $JELLYFIN_API_KEY. The server must see
+// the library at the same paths as epify.
 //
 // Examples:
 //
@@ -32,6 +171,11 @@
 //
 //	$ epify show 'The Office' 2005 73244 '/media/shows'
 //
+// Create the show directory for "The Office", searching TVDB for its year and
+// ID:
+//
+//	$ epify show 'The Office' '/media/shows'
+//
 // Add movie to `/media/movies`:
 //
 //	$ epify movie 'Braveheart' 1995 197 '/media/movies' '/downloads/braveheart.mkv'
@@ -46,6 +190,11 @@
 //
 //	$ epify season -m 1 4 '/media/shows/Breaking Bad (2008) [tvdbid-81189]' /downloads/breaking_bad_s4_p1/s4ep*.mkv
 //
+// Populate season directory `/media/shows/24 (2001) [tvdbid-76290]/Season 03`,
+// reading episode numbers like "S03E07" despite the number in the show name:
+//
+//	$ epify season -k -re sxxeyy 3 '/media/shows/24 (2001) [tvdbid-76290]' /downloads/24.S03.1080p/*.mkv
+//
 // Add episodes to `/media/shows/The Office (2005) [tvdbid-73244]/Season 03`:
 //
 //	$ epify add '/media/shows/The Office (2005) [tvdbid-73244]/Season 03' /downloads/the_office_s3_p2/ep*.mkv
@@ -54,32 +203,175 @@
 //
 //	$ epify add -m 1 '/media/shows/Breaking Bad (2008) [tvdbid-81189]/Season 04' /downloads/breaking_bad_s4_p2/s4ep*.mkv
 //
+// Populate specials directory `/media/shows/The Office (2005) [tvdbid-73244]/Specials`:
+//
+//	$ epify season -s 0 '/media/shows/The Office (2005) [tvdbid-73244]' /downloads/the_office_specials/*.mkv
+//
+// Add episodes 3 and 5 to
+// `/media/shows/The Office (2005) [tvdbid-73244]/Season 03` as E03 and E05:
+//
+//	$ epify add -k '/media/shows/The Office (2005) [tvdbid-73244]/Season 03' /downloads/ep03.mkv /downloads/ep05.mkv
+//
+// Print how episodes would be added to
+// `/media/shows/Breaking Bad (2008) [tvdbid-81189]/Season 04`:
+//
+//	$ epify add -n -m 1 '/media/shows/Breaking Bad (2008) [tvdbid-81189]/Season 04' /downloads/breaking_bad_s4_p2/s4ep*.mkv
+//
+// Import downloaded episodes into the show directories in `/media/shows`:
+//
+//	$ epify import '/media/shows' /downloads/The.Office.S03E07.1080p.WEB.mkv /downloads/Breaking.Bad.S04.1080p.BluRay
+//
+// Watch `/downloads/shows` and import finished episodes into `/media/shows`,
+// leaving the downloads in place for seeding:
+//
+//	$ epify watch -p link '/media/shows' /downloads/shows
+//
+// Hard link a movie into `/media/movies`, leaving the download in place:
+//
+//	$ epify movie -p link 'Braveheart' 1995 197 '/media/movies' '/downloads/braveheart.mkv'
+//
+// Add a movie labeled with its IMDb and TMDB IDs to `/media/movies`:
+//
+//	$ epify movie 'Braveheart' 1995 imdbid-tt0112573,tmdbid-197 '/media/movies' '/downloads/braveheart.mkv'
+//
+// Cache the metadata of the shows in `/media/shows` for offline lookups:
+//
+//	$ epify cache warm '/media/shows'
+//
+// Report naming problems in `/media/shows` as JSON:
+//
+//	$ epify check -json '/media/shows'
+//
+// Report missing episodes in every show in `/media/shows`:
+//
+//	$ epify gaps /media/shows/*
+//
+// Undo the most recent command:
+//
+//	$ epify undo
+//
 // [shows]: https://jellyfin.org/docs/general/server/media/shows/
 // [movies]: https://jellyfin.org/docs/general/server/media/movies/
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
 
-	"github.com/matthewdargan/epify/internal/media"
+	"github.com/matthewdargan/epify/internal/watch"
+	"github.com/matthewdargan/epify/media"
 )
 
 var (
+	showCmd     = flag.NewFlagSet("show", flag.ExitOnError)
+	showDry     = showCmd.Bool("n", false, "print plan without applying it")
+	showNames   = names(showCmd)
+	movieCmd    = flag.NewFlagSet("movie", flag.ExitOnError)
+	movieDry    = movieCmd.Bool("n", false, "print plan without applying it")
+	movieMode   = placement(movieCmd)
+	movieConf   = conflict(movieCmd)
+	movieNames  = names(movieCmd)
 	seasonCmd   = flag.NewFlagSet("season", flag.ExitOnError)
+	seasonDry   = seasonCmd.Bool("n", false, "print plan without applying it")
 	seasonMatch = seasonCmd.Int("m", 0, "match index")
+	seasonRe    = matcher(seasonCmd)
+	seasonKeep  = seasonCmd.Bool("k", false, "keep episode numbers at match index")
+	seasonSpec  = seasonCmd.Bool("s", false, "label season 0 directory Specials")
+	seasonMode  = placement(seasonCmd)
+	seasonConf  = conflict(seasonCmd)
+	seasonNames = names(seasonCmd)
+	seasonJobs  = jobs(seasonCmd)
 	addCmd      = flag.NewFlagSet("add", flag.ExitOnError)
+	addDry      = addCmd.Bool("n", false, "print plan without applying it")
 	addMatch    = addCmd.Int("m", 0, "match index")
+	addRe       = matcher(addCmd)
+	addKeep     = addCmd.Bool("k", false, "keep episode numbers at match index")
+	addGaps     = addCmd.Bool("g", false, "fill gaps before the last episode first")
+	addMode     = placement(addCmd)
+	addConf     = conflict(addCmd)
+	addNames    = names(addCmd)
+	addJobs     = jobs(addCmd)
+	importCmd   = flag.NewFlagSet("import", flag.ExitOnError)
+	importDry   = importCmd.Bool("n", false, "print plan without applying it")
+	importSpec  = importCmd.Bool("s", false, "label season 0 directories Specials")
+	importMode  = placement(importCmd)
+	importConf  = conflict(importCmd)
+	importNames = names(importCmd)
+	importJobs  = jobs(importCmd)
+	watchCmd    = flag.NewFlagSet("watch", flag.ExitOnError)
+	watchSettle = watchCmd.Duration("d", 30*time.Second, "`duration` files must stop changing for")
+	watchLog    = watchCmd.String("l", "", "processed file `log`")
+	watchSpec   = watchCmd.Bool("s", false, "label season 0 directories Specials")
+	watchMode   = placement(watchCmd)
+	watchConf   = conflict(watchCmd)
+	watchNames  = names(watchCmd)
+	watchJobs   = jobs(watchCmd)
+	checkCmd    = flag.NewFlagSet("check", flag.ExitOnError)
+	checkJSON   = checkCmd.Bool("json", false, "print problems as JSON")
+	gapsCmd     = flag.NewFlagSet("gaps", flag.ExitOnError)
+	gapsJSON    = gapsCmd.Bool("json", false, "print reports as JSON")
+	undoCmd     = flag.NewFlagSet("undo", flag.ExitOnError)
+	undoDry     = undoCmd.Bool("n", false, "print plan without applying it")
+	undoList    = undoCmd.Bool("l", false, "list journal entries")
 )
 
+// conflict defines the conflict policy flag for fs.
+func conflict(fs *flag.FlagSet) *media.ConflictPolicy {
+	c := new(media.ConflictPolicy)
+	fs.TextVar(c, "c", media.Fail, "conflict `policy`: fail, skip, suffix, or replace-larger")
+	return c
+}
+
+// jobs defines the concurrency flag for fs.
+func jobs(fs *flag.FlagSet) *int {
+	return fs.Int("j", media.DefaultConcurrency, "maximum files placed at once, or `jobs`")
+}
+
+// matcher defines the episode matcher flag for fs.
+func matcher(fs *flag.FlagSet) *media.Matcher {
+	m := new(media.Matcher)
+	fs.TextVar(m, "re", media.Matcher{}, "episode `regexp` with named groups, or preset: sxxeyy, nxnn, or episode")
+	return m
+}
+
+// names defines the file name profile flag for fs.
+func names(fs *flag.FlagSet) *media.NameProfile {
+	p := new(media.NameProfile)
+	fs.TextVar(p, "f", media.POSIX, "file name `profile`: posix, windows, or smb")
+	return p
+}
+
+// placement defines the placement mode flag for fs.
+func placement(fs *flag.FlagSet) *media.OpKind {
+	k := new(media.OpKind)
+	fs.TextVar(k, "p", media.Rename, "placement `mode`: rename, link, symlink, reflink, or copy")
+	return k
+}
+
 func usage() {
 	fmt.Fprintf(os.Stderr, "usage:\n")
-	fmt.Fprintf(os.Stderr, "\tepify show name year tvdbid dir\n")
-	fmt.Fprintf(os.Stderr, "\tepify movie name year tmdbid dir movie\n")
-	fmt.Fprintf(os.Stderr, "\tepify season [-m index] seasonnum showdir episode...\n")
-	fmt.Fprintf(os.Stderr, "\tepify add [-m index] seasondir episode...\n")
+	fmt.Fprintf(os.Stderr, "\tepify show [-n] [-f profile] name [year id] dir\n")
+	fmt.Fprintf(os.Stderr, "\tepify movie [-n] [-c policy] [-f profile] [-p mode] name [year id] dir movie\n")
+	fmt.Fprintf(os.Stderr, "\tepify season [-k] [-n] [-s] [-c policy] [-f profile] [-j jobs] [-m index] [-p mode] [-re regexp] seasonnum showdir episode...\n")
+	fmt.Fprintf(os.Stderr, "\tepify add [-g] [-k] [-n] [-c policy] [-f profile] [-j jobs] [-m index] [-p mode] [-re regexp] seasondir episode...\n")
+	fmt.Fprintf(os.Stderr, "\tepify import [-n] [-s] [-c policy] [-f profile] [-j jobs] [-p mode] library path...\n")
+	fmt.Fprintf(os.Stderr, "\tepify watch [-s] [-c policy] [-d duration] [-f profile] [-j jobs] [-l log] [-p mode] library dir...\n")
+	fmt.Fprintf(os.Stderr, "\tepify check [-json] library...\n")
+	fmt.Fprintf(os.Stderr, "\tepify gaps [-json] showdir...\n")
+	fmt.Fprintf(os.Stderr, "\tepify undo [-n] [-l] [id]\n")
+	fmt.Fprintf(os.Stderr, "\tepify cache warm library...\n")
+	fmt.Fprintf(os.Stderr, "\tepify cache ls\n")
+	fmt.Fprintf(os.Stderr, "\tepify cache clear\n")
 	os.Exit(2)
 }
 
@@ -94,34 +386,68 @@ func main() {
 	args := flag.Args()
 	switch args[0] {
 	case "show":
-		if flag.NArg() != 5 {
-			usage()
+		if err := showCmd.Parse(args[1:]); err != nil {
+			log.Fatal(err)
 		}
-		s := media.Show{
-			Name: args[1],
-			Year: args[2],
-			ID:   args[3],
-			Dir:  args[4],
+		args = showCmd.Args()
+		var s media.Show
+		switch len(args) {
+		case 2:
+			t, err := tvdb()
+			if err != nil {
+				log.Fatal(err)
+			}
+			s, err = media.LookupShow(context.Background(), t, media.Show{Name: args[0], Dir: args[1]})
+			if err != nil {
+				log.Fatal(lookupErr(err, t, "TVDB_API_KEY"))
+			}
+			log.Printf("found %s (%s) [tvdbid-%s]", s.Name, s.Year, s.ID)
+		case 4:
+			var err error
+			if s, err = media.ParseIDs(args[2]); err != nil {
+				log.Fatal(err)
+			}
+			s.Name, s.Year, s.Dir = args[0], args[1], args[3]
+		default:
+			usage()
 		}
-		if err := media.MkShow(s); err != nil {
+		p, err := media.PlanShow(s, media.Options{Names: *showNames})
+		if err != nil {
 			log.Fatal(err)
 		}
+		apply(p, *showDry, 0)
 	case "movie":
-		if flag.NArg() != 6 {
-			usage()
+		if err := movieCmd.Parse(args[1:]); err != nil {
+			log.Fatal(err)
 		}
-		m := media.Movie{
-			Show: media.Show{
-				Name: args[1],
-				Year: args[2],
-				ID:   args[3],
-				Dir:  args[4],
-			},
-			File: args[5],
+		args = movieCmd.Args()
+		var m media.Movie
+		switch len(args) {
+		case 3:
+			t, err := tmdb()
+			if err != nil {
+				log.Fatal(err)
+			}
+			m = media.Movie{Show: media.Show{Name: args[0], Dir: args[1]}, File: args[2]}
+			if m, err = media.LookupMovie(context.Background(), t, m); err != nil {
+				log.Fatal(lookupErr(err, t, "TMDB_API_KEY"))
+			}
+			log.Printf("found %s (%s) [tmdbid-%s]", m.Name, m.Year, m.ID)
+		case 5:
+			s, err := media.ParseIDs(args[2])
+			if err != nil {
+				log.Fatal(err)
+			}
+			s.Name, s.Year, s.Dir = args[0], args[1], args[3]
+			m = media.Movie{Show: s, File: args[4]}
+		default:
+			usage()
 		}
-		if err := media.AddMovie(m); err != nil {
+		p, err := media.PlanMovie(m, media.Options{Mode: *movieMode, Conflict: *movieConf, Names: *movieNames})
+		if err != nil {
 			log.Fatal(err)
 		}
+		apply(p, *movieDry, 0)
 	case "season":
 		if err := seasonCmd.Parse(args[1:]); err != nil {
 			log.Fatal(err)
@@ -132,13 +458,18 @@ func main() {
 		args = seasonCmd.Args()
 		s := media.Season{
 			N:          args[0],
+			Specials:   *seasonSpec,
 			ShowDir:    args[1],
 			Episodes:   args[2:],
 			MatchIndex: *seasonMatch,
+			Match:      *seasonRe,
+			Preserve:   *seasonKeep,
 		}
-		if err := media.MkSeason(s); err != nil {
+		p, err := media.PlanSeason(s, media.Options{Mode: *seasonMode, Conflict: *seasonConf, Names: *seasonNames})
+		if err != nil {
 			log.Fatal(err)
 		}
+		apply(p, *seasonDry, *seasonJobs)
 	case "add":
 		if err := addCmd.Parse(args[1:]); err != nil {
 			log.Fatal(err)
@@ -151,11 +482,480 @@ func main() {
 			SeasonDir:  args[0],
 			Episodes:   args[1:],
 			MatchIndex: *addMatch,
+			Match:      *addRe,
+			Preserve:   *addKeep,
+			FillGaps:   *addGaps,
+		}
+		p, err := media.PlanAddition(a, media.Options{Mode: *addMode, Conflict: *addConf, Names: *addNames})
+		if err != nil {
+			log.Fatal(err)
+		}
+		apply(p, *addDry, *addJobs)
+	case "import":
+		if err := importCmd.Parse(args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		if importCmd.NArg() < 2 {
+			usage()
+		}
+		args = importCmd.Args()
+		im := media.Import{
+			Library:  args[0],
+			Paths:    args[1:],
+			Specials: *importSpec,
+		}
+		p, err := media.PlanImport(im, media.Options{Mode: *importMode, Conflict: *importConf, Names: *importNames})
+		if err != nil {
+			log.Fatal(err)
+		}
+		apply(p, *importDry, *importJobs)
+	case "watch":
+		if err := watchCmd.Parse(args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		if watchCmd.NArg() < 2 {
+			usage()
+		}
+		args = watchCmd.Args()
+		if *watchLog == "" {
+			dir, err := stateDir()
+			if err != nil {
+				log.Fatal(err)
+			}
+			*watchLog = filepath.Join(dir, "watch.log")
+		}
+		if err := watchDirs(args[0], args[1:]); err != nil {
+			log.Fatal(err)
+		}
+	case "check":
+		if err := checkCmd.Parse(args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		if checkCmd.NArg() < 1 {
+			usage()
+		}
+		ps := []media.Problem{}
+		for _, library := range checkCmd.Args() {
+			lps, err := media.Check(library)
+			if err != nil {
+				log.Fatal(err)
+			}
+			ps = append(ps, lps...)
+		}
+		if *checkJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "\t")
+			if err := enc.Encode(ps); err != nil {
+				log.Fatal(err)
+			}
+		} else {
+			for _, p := range ps {
+				fmt.Println(p)
+			}
+		}
+		if len(ps) > 0 {
+			os.Exit(1)
+		}
+	case "gaps":
+		if err := gapsCmd.Parse(args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		if gapsCmd.NArg() < 1 {
+			usage()
+		}
+		rs, err := gaps(gapsCmd.Args())
+		if err != nil {
+			log.Fatal(err)
+		}
+		if *gapsJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "\t")
+			if err = enc.Encode(rs); err != nil {
+				log.Fatal(err)
+			}
+		} else {
+			for _, r := range rs {
+				fmt.Println(r)
+			}
+		}
+		if len(rs) > 0 {
+			os.Exit(1)
+		}
+	case "undo":
+		if err := undoCmd.Parse(args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		if undoCmd.NArg() > 1 {
+			usage()
+		}
+		j, err := journal()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if *undoList {
+			if err = list(j); err != nil {
+				log.Fatal(err)
+			}
+			break
+		}
+		var id int
+		if undoCmd.NArg() == 1 {
+			if id, err = strconv.Atoi(undoCmd.Arg(0)); err != nil {
+				log.Fatalf("invalid journal id: %v", err)
+			}
+		}
+		e, err := j.Undoable(id)
+		if err != nil {
+			log.Fatal(err)
+		}
+		p, err := e.Inverse()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if *undoDry {
+			fmt.Print(p)
+			break
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		u, err := j.Undo(ctx, p, e.ID, media.Options{Progress: progress})
+		if errors.Is(err, context.Canceled) {
+			interrupted(u, p)
+			os.Exit(1)
+		}
+		if err != nil {
+			log.Fatal(err)
 		}
-		if err := media.AddEpisodes(a); err != nil {
+		refresh(p)
+	case "cache":
+		if err := runCache(args[1:]); err != nil {
 			log.Fatal(err)
 		}
 	default:
 		usage()
 	}
 }
+
+// apply applies p, placing up to jobs files at once, or prints it if dry is
+// set. If interrupted by SIGINT or SIGTERM, apply stops and reports the
+// operations that completed.
+func apply(p media.Plan, dry bool, jobs int) {
+	if dry {
+		fmt.Print(p)
+		return
+	}
+	j, err := journal()
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	e, err := j.Apply(ctx, p, media.Options{Concurrency: jobs, Progress: progress})
+	if errors.Is(err, context.Canceled) {
+		interrupted(e, p)
+		os.Exit(1)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	refresh(p)
+}
+
+// interrupted reports the operations of p that completed before it was
+// interrupted, recorded in e, and asks Jellyfin to scan the paths they
+// changed.
+func interrupted(e media.Entry, p media.Plan) {
+	log.Printf("interrupted after %d of %d operations", len(e.Ops), len(p.Ops))
+	if len(e.Ops) == 0 {
+		return
+	}
+	for _, o := range e.Ops {
+		log.Printf("\t%v", o)
+	}
+	log.Printf("run `epify undo %d` to reverse them", e.ID)
+	refresh(media.Plan{Ops: e.Ops})
+}
+
+// refresh asks the Jellyfin server at $JELLYFIN_URL, if defined, to scan the
+// paths affected by applying p, authenticating with $JELLYFIN_API_KEY.
+func refresh(p media.Plan) {
+	url := os.Getenv("JELLYFIN_URL")
+	if url == "" {
+		return
+	}
+	jf := media.Jellyfin{URL: url, Key: os.Getenv("JELLYFIN_API_KEY")}
+	if err := jf.Refresh(context.Background(), p); err != nil {
+		log.Printf("refresh jellyfin: %v", err)
+	}
+}
+
+// progress reports the progress of a copy across filesystems.
+func progress(o media.Op, written, size int64) {
+	log.Printf("copying %s: %d%%", o.Src, written*100/size)
+}
+
+// gaps returns the gap reports for the season directories in showDirs,
+// checking for episodes missing at the end of seasons against the TVDB
+// episodes of shows labeled with a TVDB ID when they can be looked up.
+func gaps(showDirs []string) ([]media.GapReport, error) {
+	t, err := tvdb()
+	if err != nil {
+		return nil, err
+	}
+	rs := []media.GapReport{}
+	for _, dir := range showDirs {
+		var es []media.Episode
+		if s, err := media.ParseShowDir(filepath.Base(dir)); err == nil && s.TVDBID != "" {
+			if es, err = t.Episodes(context.Background(), s.TVDBID); err != nil {
+				if !errors.Is(err, media.ErrNotCached) {
+					log.Printf("%s: %v", dir, err)
+				}
+				es = nil
+			}
+		}
+		srs, err := media.Gaps(dir, es)
+		if err != nil {
+			return nil, err
+		}
+		rs = append(rs, srs...)
+	}
+	return rs, nil
+}
+
+// tvdb returns the TVDB metadata cache, fetching from the TVDB client
+// configured by $TVDB_API_KEY, $TVDB_PIN, and $TVDB_URL if $TVDB_API_KEY is
+// defined.
+func tvdb() (*media.Cache, error) {
+	c, err := metadataCache("tvdb")
+	if err != nil {
+		return nil, err
+	}
+	if key := os.Getenv("TVDB_API_KEY"); key != "" {
+		c.Provider = &media.TVDB{BaseURL: os.Getenv("TVDB_URL"), Key: key, PIN: os.Getenv("TVDB_PIN")}
+	}
+	return c, nil
+}
+
+// tmdb returns the TMDB metadata cache, fetching from the TMDB client
+// configured by $TMDB_API_KEY and $TMDB_URL if $TMDB_API_KEY is defined.
+func tmdb() (*media.Cache, error) {
+	c, err := metadataCache("tmdb")
+	if err != nil {
+		return nil, err
+	}
+	if key := os.Getenv("TMDB_API_KEY"); key != "" {
+		c.Provider = &media.TMDB{BaseURL: os.Getenv("TMDB_URL"), Key: key}
+	}
+	return c, nil
+}
+
+// metadataCache returns the metadata cache for provider at $EPIFY_CACHE, or
+// in the epify directory in the user cache directory.
+func metadataCache(provider string) (*media.Cache, error) {
+	dir := os.Getenv("EPIFY_CACHE")
+	if dir == "" {
+		d, err := os.UserCacheDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(d, "epify")
+	}
+	return &media.Cache{Dir: filepath.Join(dir, provider)}, nil
+}
+
+// lookupErr explains err, returned by a lookup in c, if c could not fetch
+// a lookup it does not have cached because keyVar is not defined.
+func lookupErr(err error, c *media.Cache, keyVar string) error {
+	if errors.Is(err, media.ErrNotCached) && c.Provider == nil {
+		return fmt.Errorf("%w and $%s is not defined", err, keyVar)
+	}
+	return err
+}
+
+// runCache runs the `epify cache` command with args.
+func runCache(args []string) error {
+	if len(args) < 1 {
+		usage()
+	}
+	tv, err := tvdb()
+	if err != nil {
+		return err
+	}
+	tm, err := tmdb()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	switch args[0] {
+	case "warm":
+		if len(args) < 2 {
+			usage()
+		}
+		if tv.Provider == nil && tm.Provider == nil {
+			return errors.New("neither $TVDB_API_KEY nor $TMDB_API_KEY are defined")
+		}
+		for _, library := range args[1:] {
+			if tv.Provider != nil {
+				shows, err := tv.WarmShows(ctx, library, "tvdbid")
+				if err != nil {
+					return err
+				}
+				for _, s := range shows {
+					log.Printf("cached %s [tvdbid-%s]", s.Name, s.ID)
+				}
+			}
+			if tm.Provider != nil {
+				movies, err := tm.WarmMovies(ctx, library, "tmdbid")
+				if err != nil {
+					return err
+				}
+				for _, m := range movies {
+					log.Printf("cached %s [tmdbid-%s]", m.Name, m.ID)
+				}
+			}
+		}
+	case "ls":
+		if len(args) != 1 {
+			usage()
+		}
+		for _, c := range []struct {
+			name string
+			*media.Cache
+		}{{"tvdb", tv}, {"tmdb", tm}} {
+			shows, err := c.Shows()
+			if err != nil {
+				return err
+			}
+			movies, err := c.Movies()
+			if err != nil {
+				return err
+			}
+			for _, s := range shows {
+				fmt.Printf("%s show %s\t%s (%s)", c.name, s.ID, s.Name, s.Year)
+				offline := media.Cache{Dir: c.Dir}
+				if es, err := offline.Episodes(ctx, s.ID); err == nil {
+					fmt.Printf("\t%d episodes", len(es))
+				}
+				fmt.Println()
+			}
+			for _, m := range movies {
+				fmt.Printf("%s movie %s\t%s (%s)\n", c.name, m.ID, m.Name, m.Year)
+			}
+		}
+	case "clear":
+		if len(args) != 1 {
+			usage()
+		}
+		if err = tv.Clear(); err != nil {
+			return err
+		}
+		return tm.Clear()
+	default:
+		usage()
+	}
+	return nil
+}
+
+// journal returns the journal at $EPIFY_JOURNAL, or at journal.jsonl in the
+// state directory.
+func journal() (media.Journal, error) {
+	if path := os.Getenv("EPIFY_JOURNAL"); path != "" {
+		return media.Journal{Path: path}, nil
+	}
+	dir, err := stateDir()
+	if err != nil {
+		return media.Journal{}, err
+	}
+	return media.Journal{Path: filepath.Join(dir, "journal.jsonl")}, nil
+}
+
+// stateDir returns the epify directory in the XDG state directory.
+func stateDir() (string, error) {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", errors.New("neither $XDG_STATE_HOME nor $HOME are defined")
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "epify"), nil
+}
+
+// watchDirs imports video files into library as they finish downloading into
+// dirs, until interrupted.
+func watchDirs(library string, dirs []string) error {
+	for i, d := range dirs {
+		abs, err := filepath.Abs(d)
+		if err != nil {
+			return err
+		}
+		dirs[i] = abs
+	}
+	wl, err := watch.OpenLog(*watchLog)
+	if err != nil {
+		return err
+	}
+	defer wl.Close()
+	j, err := journal()
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return watch.Watch(ctx, dirs, *watchSettle, func(path string) {
+		info, err := os.Stat(path)
+		if err != nil || !media.IsVideo(path) || wl.Done(path, info.Size()) {
+			return
+		}
+		im := media.Import{
+			Library:  library,
+			Paths:    []string{path},
+			Specials: *watchSpec,
+		}
+		p, err := media.PlanImport(im, media.Options{Mode: *watchMode, Conflict: *watchConf, Names: *watchNames})
+		if err == nil {
+			var e media.Entry
+			e, err = j.Apply(ctx, p, media.Options{Concurrency: *watchJobs, Progress: progress})
+			if errors.Is(err, context.Canceled) {
+				interrupted(e, p)
+				return
+			}
+		}
+		if err != nil {
+			log.Printf("import %s: %v", path, err)
+			return
+		}
+		for _, o := range p.Ops {
+			if o.Src != "" {
+				log.Printf("imported %s to %s", o.Src, o.Dst)
+			}
+		}
+		refresh(p)
+		if err = wl.Add(path, info.Size()); err != nil {
+			log.Print(err)
+		}
+	})
+}
+
+// list prints the entries in j.
+func list(j media.Journal) error {
+	es, err := j.Entries()
+	if err != nil {
+		return err
+	}
+	for _, e := range es {
+		fmt.Printf("%d %s", e.ID, e.Time.Format("2006-01-02 15:04:05"))
+		switch {
+		case e.Undo != 0:
+			fmt.Printf(" (undo of %d)", e.Undo)
+		case e.UndoneBy != 0:
+			fmt.Printf(" (undone by %d)", e.UndoneBy)
+		}
+		fmt.Println()
+		for _, o := range e.Ops {
+			fmt.Printf("\t%v\n", o)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,307 @@
+// Copyright 2024 Matthew P. Dargan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package media
+
+import (
+	"cmp"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// A Cache is a [MetadataProvider] that keeps the results of another provider
+// on disk. Shows and movies are stored by their provider ID, so searches for
+// names already looked up and the episodes of shows already fetched need no
+// network access. A Cache with no Provider answers from the cache alone.
+type Cache struct {
+	Dir      string           // cache directory, one per provider
+	Provider MetadataProvider // nil for offline use
+	// MaxAge is the age after which entries are fetched again. Entries never
+	// expire if MaxAge is zero, and always expire if it is negative. Expired
+	// entries are still used if fetching them again fails.
+	MaxAge time.Duration
+}
+
+// ErrNotCached is the error returned by a [Cache] with no Provider for
+// lookups that are not in the cache.
+var ErrNotCached = errors.New("not in cache")
+
+// A cacheRecord is a show or movie stored in a [Cache].
+type cacheRecord struct {
+	Name string `json:"name"`
+	Year string `json:"year,omitempty"`
+	ID   string `json:"id"`
+}
+
+// SearchShows implements [MetadataProvider].
+func (c *Cache) SearchShows(ctx context.Context, name string) ([]Show, error) {
+	return c.search("shows", name, func() ([]Show, error) {
+		return c.Provider.SearchShows(ctx, name)
+	})
+}
+
+// SearchMovies implements [MetadataProvider].
+func (c *Cache) SearchMovies(ctx context.Context, name string) ([]Movie, error) {
+	shows, err := c.search("movies", name, func() ([]Show, error) {
+		movies, err := c.Provider.SearchMovies(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		shows := make([]Show, len(movies))
+		for i, m := range movies {
+			shows[i] = m.Show
+		}
+		return shows, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	movies := make([]Movie, len(shows))
+	for i, s := range shows {
+		movies[i] = Movie{Show: s}
+	}
+	return movies, nil
+}
+
+// Episodes implements [MetadataProvider].
+func (c *Cache) Episodes(ctx context.Context, id string) ([]Episode, error) {
+	var es []Episode
+	err := c.cached(filepath.Join("episodes", url.PathEscape(id)+".json"), "episodes of "+id, &es, func() error {
+		fetched, err := c.Provider.Episodes(ctx, id)
+		if err != nil {
+			return err
+		}
+		es = fetched
+		return nil
+	})
+	return es, err
+}
+
+// search returns the results for name in the kind index of c, fetching them
+// and storing each result by ID if necessary.
+func (c *Cache) search(kind, name string, fetch func() ([]Show, error)) ([]Show, error) {
+	var ids []string
+	err := c.cached(filepath.Join("search", kind, searchKey(name)+".json"), fmt.Sprintf("%q", name), &ids, func() error {
+		shows, err := fetch()
+		if err != nil {
+			return err
+		}
+		fetched := make([]string, len(shows))
+		for i, s := range shows {
+			r := cacheRecord{Name: s.Name, Year: s.Year, ID: s.ID}
+			if err = c.write(filepath.Join(kind, url.PathEscape(s.ID)+".json"), r); err != nil {
+				return err
+			}
+			fetched[i] = s.ID
+		}
+		ids = fetched
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	shows := make([]Show, len(ids))
+	for i, id := range ids {
+		var r cacheRecord
+		if _, err = c.read(filepath.Join(kind, url.PathEscape(id)+".json"), &r); err != nil {
+			return nil, err
+		}
+		shows[i] = Show{Name: r.Name, Year: r.Year, ID: r.ID}
+	}
+	return shows, nil
+}
+
+// searchKey returns the name of the search index entry for name.
+func searchKey(name string) string {
+	if k := normalize(name); k != "" {
+		return k
+	}
+	return "_" + hex.EncodeToString([]byte(name))
+}
+
+// cached decodes the cache file at name into v, calling fetch to fill v and
+// storing it if the file is missing or expired. what describes the entry in
+// errors.
+func (c *Cache) cached(name, what string, v any, fetch func() error) error {
+	mod, err := c.read(name, v)
+	if err == nil && (c.Provider == nil || c.MaxAge == 0 || time.Since(mod) <= c.MaxAge) {
+		return nil
+	}
+	if c.Provider == nil {
+		return fmt.Errorf("%s %w", what, ErrNotCached)
+	}
+	if ferr := fetch(); ferr != nil {
+		if err == nil {
+			return nil
+		}
+		return ferr
+	}
+	return c.write(name, v)
+}
+
+// read decodes the cache file at name into v and returns its modification
+// time.
+func (c *Cache) read(name string, v any) (time.Time, error) {
+	f, err := os.Open(filepath.Join(c.Dir, name))
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return time.Time{}, err
+	}
+	if err = json.NewDecoder(f).Decode(v); err != nil {
+		return time.Time{}, fmt.Errorf("%s: %w", f.Name(), err)
+	}
+	return info.ModTime(), nil
+}
+
+// write stores v in the cache file at name, replacing it atomically.
+func (c *Cache) write(name string, v any) error {
+	path := filepath.Join(c.Dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err = json.NewEncoder(f).Encode(v); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// Shows returns the shows in c, sorted by name.
+func (c *Cache) Shows() ([]Show, error) {
+	return c.records("shows")
+}
+
+// Movies returns the movies in c, sorted by name.
+func (c *Cache) Movies() ([]Movie, error) {
+	shows, err := c.records("movies")
+	if err != nil {
+		return nil, err
+	}
+	movies := make([]Movie, len(shows))
+	for i, s := range shows {
+		movies[i] = Movie{Show: s}
+	}
+	return movies, nil
+}
+
+// records returns the records of the given kind in c, sorted by name.
+func (c *Cache) records(kind string) ([]Show, error) {
+	ents, err := os.ReadDir(filepath.Join(c.Dir, kind))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var shows []Show
+	for _, ent := range ents {
+		if ent.IsDir() || filepath.Ext(ent.Name()) != ".json" || strings.HasPrefix(ent.Name(), ".") {
+			continue
+		}
+		var r cacheRecord
+		if _, err = c.read(filepath.Join(kind, ent.Name()), &r); err != nil {
+			return nil, err
+		}
+		shows = append(shows, Show{Name: r.Name, Year: r.Year, ID: r.ID})
+	}
+	slices.SortFunc(shows, func(a, b Show) int {
+		return cmp.Or(cmp.Compare(a.Name, b.Name), cmp.Compare(a.Year, b.Year))
+	})
+	return shows, nil
+}
+
+// Clear removes every entry in c.
+func (c *Cache) Clear() error {
+	return os.RemoveAll(c.Dir)
+}
+
+// WarmShows fetches into c the search results for the name of each show
+// directory in library labeled with tag, like "tvdbid", and the episodes of
+// the show with the tagged ID. Cached entries are fetched again. It returns
+// the shows found in library.
+func (c *Cache) WarmShows(ctx context.Context, library, tag string) ([]Show, error) {
+	dirs, err := showDirs(library)
+	if err != nil {
+		return nil, err
+	}
+	w := *c
+	w.MaxAge = -1
+	var shows []Show
+	for _, dir := range dirs {
+		s, ok := taggedName(filepath.Base(dir), tag)
+		if !ok {
+			continue
+		}
+		if _, err = w.SearchShows(ctx, s.Name); err != nil {
+			return nil, err
+		}
+		if _, err = w.Episodes(ctx, s.ID); err != nil {
+			return nil, err
+		}
+		s.Dir = library
+		shows = append(shows, s)
+	}
+	return shows, nil
+}
+
+// WarmMovies fetches into c the search results for the name of each movie in
+// library labeled with tag, like "tmdbid". Cached entries are fetched again.
+// It returns the movies found in library.
+func (c *Cache) WarmMovies(ctx context.Context, library, tag string) ([]Movie, error) {
+	ents, err := os.ReadDir(library)
+	if err != nil {
+		return nil, fmt.Errorf("invalid library: %w", err)
+	}
+	w := *c
+	w.MaxAge = -1
+	var movies []Movie
+	for _, ent := range ents {
+		if ent.IsDir() || !IsVideo(ent.Name()) {
+			continue
+		}
+		s, ok := taggedName(strings.TrimSuffix(ent.Name(), filepath.Ext(ent.Name())), tag)
+		if !ok {
+			continue
+		}
+		if _, err = w.SearchMovies(ctx, s.Name); err != nil {
+			return nil, err
+		}
+		s.Dir = library
+		movies = append(movies, Movie{Show: s, File: filepath.Join(library, ent.Name())})
+	}
+	return movies, nil
+}
+
+// taggedName parses names like "Series Name (2018) [tvdbid-65567]", reporting
+// whether name has an ID labeled with tag. The ID is the show's ID.
+func taggedName(name, tag string) (Show, bool) {
+	s, err := parseLabeled(name)
+	if err != nil {
+		return Show{}, false
+	}
+	ids := map[string]string{"imdbid": s.IMDBID, "tmdbid": s.TMDBID, "tvdbid": s.TVDBID}
+	return Show{Name: s.Name, Year: s.Year, ID: ids[tag]}, ids[tag] != ""
+}
@@ -0,0 +1,215 @@
+// Copyright 2024 Matthew P. Dargan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package media
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// A ProblemKind is the kind of naming problem a [Problem] reports.
+type ProblemKind int
+
+const (
+	BadShowDir   ProblemKind = iota // show directory not like "Series Name (2018) [tvdbid-65567]"
+	BadSeasonDir                    // season directory not like "Season 01" or SpecialsDir
+	BadEpisode                      // episode not like "Series Name S01E01" in its season
+	StrayFile                       // file that is not a video, sidecar, or artwork
+)
+
+var problemKinds = []string{
+	BadShowDir:   "show",
+	BadSeasonDir: "season",
+	BadEpisode:   "episode",
+	StrayFile:    "stray",
+}
+
+func (k ProblemKind) String() string {
+	if k >= 0 && int(k) < len(problemKinds) {
+		return problemKinds[k]
+	}
+	return fmt.Sprintf("ProblemKind(%d)", int(k))
+}
+
+// MarshalText implements [encoding.TextMarshaler].
+func (k ProblemKind) MarshalText() ([]byte, error) {
+	if k < 0 || int(k) >= len(problemKinds) {
+		return nil, fmt.Errorf("unknown problem %d", int(k))
+	}
+	return []byte(problemKinds[k]), nil
+}
+
+// UnmarshalText implements [encoding.TextUnmarshaler].
+func (k *ProblemKind) UnmarshalText(b []byte) error {
+	i := slices.Index(problemKinds, string(b))
+	if i < 0 {
+		return fmt.Errorf("unknown problem %q", b)
+	}
+	*k = ProblemKind(i)
+	return nil
+}
+
+// A Problem is a path in a library that breaks the naming scheme.
+type Problem struct {
+	Kind    ProblemKind `json:"kind"`
+	Path    string      `json:"path"`
+	Message string      `json:"message"`
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %v: %s", p.Path, p.Kind, p.Message)
+}
+
+// artExts are the extensions of artwork files Jellyfin reads beside shows,
+// seasons, and episodes, like "poster.jpg".
+var artExts = []string{".gif", ".jpeg", ".jpg", ".png", ".tbn", ".webp"}
+
+// extrasDirs are the names of the directories Jellyfin reads extras from.
+var extrasDirs = []string{
+	"backdrops", "behind the scenes", "clips", "deleted scenes", "extras",
+	"featurettes", "interviews", "other", "samples", "scenes", "shorts",
+	"theme-music", "trailers",
+}
+
+// Check walks the show directories in library and returns the paths that
+// break the naming [MkShow], [MkSeason], and [AddEpisodes] produce:
+// show directories without a provider ID, season directories not
+// labeled like "Season 01", episodes whose season and episode numbers do
+// not match their season directory, and stray files that are not videos,
+// sidecars, or artwork. Extras directories, like "Extras", and the backups
+// of replaced episodes are not checked.
+func Check(library string) ([]Problem, error) {
+	ents, err := os.ReadDir(library)
+	if err != nil {
+		return nil, fmt.Errorf("invalid library: %w", err)
+	}
+	var ps []Problem
+	for _, ent := range ents {
+		path := filepath.Join(library, ent.Name())
+		if !ent.IsDir() {
+			ps = append(ps, checkLoose(path, "not in a show directory"))
+			continue
+		}
+		s, err := parseLabeled(ent.Name())
+		if err != nil {
+			ps = append(ps, Problem{BadShowDir, path, err.Error()})
+		}
+		if err == nil && s.TVDBID == "" && s.TMDBID == "" && s.IMDBID == "" {
+			ps = append(ps, Problem{BadShowDir, path, "missing [tvdbid-N], [tmdbid-N], or [imdbid-ttN] tag"})
+		}
+		sps, err := checkShow(path)
+		if err != nil {
+			return nil, err
+		}
+		ps = append(ps, sps...)
+	}
+	return ps, nil
+}
+
+// checkShow returns the problems in the show directory at dir.
+func checkShow(dir string) ([]Problem, error) {
+	ents, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var ps []Problem
+	for _, ent := range ents {
+		path := filepath.Join(dir, ent.Name())
+		if !ent.IsDir() {
+			if !isArt(path) && !strings.EqualFold(filepath.Ext(path), ".nfo") && !strings.EqualFold(ent.Name(), "theme.mp3") {
+				ps = append(ps, checkLoose(path, "not artwork or metadata"))
+			}
+			continue
+		}
+		if isExtrasDir(ent.Name()) {
+			continue
+		}
+		n := 0
+		if ent.Name() != SpecialsDir {
+			m := seasonDirRe.FindStringSubmatch(ent.Name())
+			if m == nil {
+				ps = append(ps, Problem{BadSeasonDir, path, `not labeled like "Season 01" or "Specials"`})
+				continue
+			}
+			if len(m[1]) < 2 {
+				ps = append(ps, Problem{BadSeasonDir, path, "season number must have at least two digits"})
+			}
+			n, _ = strconv.Atoi(m[1])
+		}
+		sps, err := checkSeason(path, n)
+		if err != nil {
+			return nil, err
+		}
+		ps = append(ps, sps...)
+	}
+	return ps, nil
+}
+
+// checkSeason returns the problems in the directory at dir for season n.
+func checkSeason(dir string, n int) ([]Problem, error) {
+	ents, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var eps []string
+	for _, ent := range ents {
+		if !ent.IsDir() && IsVideo(ent.Name()) {
+			eps = append(eps, filepath.Join(dir, ent.Name()))
+		}
+	}
+	var ps []Problem
+	for _, ent := range ents {
+		path := filepath.Join(dir, ent.Name())
+		switch {
+		case isBackup(ent.Name()):
+		case ent.IsDir():
+			if !isExtrasDir(ent.Name()) {
+				ps = append(ps, Problem{StrayFile, path, "unexpected directory"})
+			}
+		case IsVideo(path):
+			e, err := parseEpisode(ent.Name())
+			if err != nil {
+				ps = append(ps, Problem{BadEpisode, path, err.Error()})
+				continue
+			}
+			if e.Season != n {
+				ps = append(ps, Problem{BadEpisode, path, fmt.Sprintf("season %d in season %d directory", e.Season, n)})
+			}
+		case isArt(path) || strings.EqualFold(ent.Name(), "season.nfo"):
+		case isSidecar(path):
+			if !slices.ContainsFunc(eps, func(e string) bool {
+				return strings.HasPrefix(ent.Name(), stem(e)+".")
+			}) {
+				ps = append(ps, Problem{StrayFile, path, "no episode for sidecar"})
+			}
+		default:
+			ps = append(ps, Problem{StrayFile, path, "not a video, sidecar, or artwork"})
+		}
+	}
+	return ps, nil
+}
+
+// checkLoose returns the problem with the file at path outside a season
+// directory, described by msg if it is not a video.
+func checkLoose(path, msg string) Problem {
+	if IsVideo(path) {
+		return Problem{BadEpisode, path, "not in a season directory"}
+	}
+	return Problem{StrayFile, path, msg}
+}
+
+// isArt reports whether the file at path is an artwork file.
+func isArt(path string) bool {
+	return slices.Contains(artExts, strings.ToLower(filepath.Ext(path)))
+}
+
+// isExtrasDir reports whether name is the name of an extras directory.
+func isExtrasDir(name string) bool {
+	return slices.Contains(extrasDirs, strings.ToLower(name))
+}
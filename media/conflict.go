@@ -0,0 +1,272 @@
+// Copyright 2024 Matthew P. Dargan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package media
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// A ConflictPolicy specifies what happens when a file would be placed at a
+// path that already exists, or that another file in the same plan is placed
+// at.
+type ConflictPolicy int
+
+const (
+	Fail          ConflictPolicy = iota // refuse to build the plan
+	Skip                                // keep the existing file and skip the new one
+	Suffix                              // keep both, labeling the new file like "Series Name S01E01 (1).mkv"
+	ReplaceLarger                       // replace the existing file if the new one is of better quality or, at the same quality, larger, and skip it otherwise
+)
+
+var conflictPolicies = []string{
+	Fail:          "fail",
+	Skip:          "skip",
+	Suffix:        "suffix",
+	ReplaceLarger: "replace-larger",
+}
+
+func (c ConflictPolicy) String() string {
+	if c >= 0 && int(c) < len(conflictPolicies) {
+		return conflictPolicies[c]
+	}
+	return fmt.Sprintf("ConflictPolicy(%d)", int(c))
+}
+
+// MarshalText implements [encoding.TextMarshaler].
+func (c ConflictPolicy) MarshalText() ([]byte, error) {
+	if c < 0 || int(c) >= len(conflictPolicies) {
+		return nil, fmt.Errorf("unknown conflict policy %d", int(c))
+	}
+	return []byte(conflictPolicies[c]), nil
+}
+
+// UnmarshalText implements [encoding.TextUnmarshaler].
+func (c *ConflictPolicy) UnmarshalText(b []byte) error {
+	i := slices.Index(conflictPolicies, string(b))
+	if i < 0 {
+		return fmt.Errorf("unknown conflict policy %q", b)
+	}
+	*c = ConflictPolicy(i)
+	return nil
+}
+
+// A ConflictError reports a file that would be placed at a path that already
+// exists.
+type ConflictError struct {
+	Src, Dst string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("cannot place %q: %q already exists", e.Src, e.Dst)
+}
+
+// A placement is a file placed by a plan along with its sidecars, as
+// indexes into the plan's operations.
+type placement struct {
+	ops     []int
+	dropped bool
+}
+
+// resolve returns p with the conflicts between its placements and existing
+// files, or earlier placements in p, resolved by policy. A file and the
+// sidecars placed after it are resolved together. If policy is Fail, the
+// error joins a [*ConflictError] for each conflict.
+func resolve(p Plan, policy ConflictPolicy) (Plan, error) {
+	if policy < Fail || policy > ReplaceLarger {
+		return Plan{}, fmt.Errorf("invalid conflict policy %v", policy)
+	}
+	ops := slices.Clone(p.Ops)
+	var pls []*placement
+	for i, o := range ops {
+		if !o.Kind.IsPlacement() {
+			continue
+		}
+		if n := len(pls); n > 0 && isSidecar(o.Dst) {
+			main := ops[pls[n-1].ops[0]].Dst
+			if filepath.Dir(o.Dst) == filepath.Dir(main) && strings.HasPrefix(filepath.Base(o.Dst), stem(main)+".") {
+				pls[n-1].ops = append(pls[n-1].ops, i)
+				continue
+			}
+		}
+		pls = append(pls, &placement{ops: []int{i}})
+	}
+	claimed := make(map[string]*placement)
+	drop := make(map[int]bool)
+	exists := func(dst string) (bool, error) {
+		if claimed[dst] != nil {
+			return true, nil
+		}
+		_, err := os.Lstat(dst)
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	var errs []error
+	for _, pl := range pls {
+		var conflicts []int
+		for _, i := range pl.ops {
+			ok, err := exists(ops[i].Dst)
+			if err != nil {
+				return Plan{}, err
+			}
+			if ok {
+				conflicts = append(conflicts, i)
+			}
+		}
+		main := pl.ops[0]
+		switch {
+		case len(conflicts) == 0:
+		case policy == Fail:
+			for _, i := range conflicts {
+				errs = append(errs, &ConflictError{Src: ops[i].Src, Dst: ops[i].Dst})
+			}
+		case policy == Suffix:
+			for n := 1; ; n++ {
+				free := true
+				for _, i := range pl.ops {
+					dst := suffixed(ops[i].Dst, stem(p.Ops[main].Dst), n)
+					ok, err := exists(dst)
+					if err != nil {
+						return Plan{}, err
+					}
+					free = free && !ok
+				}
+				if free {
+					for _, i := range pl.ops {
+						ops[i].Dst = suffixed(ops[i].Dst, stem(p.Ops[main].Dst), n)
+					}
+					break
+				}
+			}
+		case conflicts[0] != main:
+			// Only sidecars conflict, so keep the existing sidecars.
+			pl.ops = slices.DeleteFunc(pl.ops, func(i int) bool { return slices.Contains(conflicts, i) })
+			for _, i := range conflicts {
+				drop[i] = true
+			}
+		case policy == Skip:
+			pl.dropped = true
+		case policy == ReplaceLarger:
+			better, err := isBetter(ops[main].Src, ops[main].Dst, claimed[ops[main].Dst], ops)
+			if err != nil {
+				return Plan{}, err
+			}
+			if !better {
+				pl.dropped = true
+				break
+			}
+			for _, i := range conflicts {
+				if prev := claimed[ops[i].Dst]; prev != nil {
+					prev.dropped = true
+					for _, j := range prev.ops {
+						delete(claimed, ops[j].Dst)
+					}
+				} else {
+					ops[i].Replace = true
+				}
+			}
+		}
+		if !pl.dropped {
+			for _, i := range pl.ops {
+				claimed[ops[i].Dst] = pl
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return Plan{}, errors.Join(errs...)
+	}
+	for _, pl := range pls {
+		if pl.dropped {
+			for _, i := range pl.ops {
+				drop[i] = true
+			}
+		}
+	}
+	p.Ops = nil
+	for i, o := range ops {
+		if !drop[i] {
+			p.Ops = append(p.Ops, o)
+		}
+	}
+	return p, nil
+}
+
+// suffixed returns dst, whose name extends the file name stem, with " (n)"
+// added after stem, like "Series Name S01E01 (1).en.srt".
+func suffixed(dst, stem string, n int) string {
+	base := filepath.Base(dst)
+	return filepath.Join(filepath.Dir(dst), fmt.Sprintf("%s (%d)%s", stem, n, strings.TrimPrefix(base, stem)))
+}
+
+// isBetter reports whether src is better than dst, the file placed by prev
+// if it is not nil, or the existing file otherwise. If the qualities of both
+// files are known, the file of higher resolution, and then of better source,
+// is better. Otherwise, the larger file is better. Directories are never
+// worse.
+func isBetter(src, dst string, prev *placement, ops []Op) (bool, error) {
+	if prev != nil {
+		dst = ops[prev.ops[0]].Src
+	}
+	s, err := os.Stat(src)
+	if err != nil {
+		return false, err
+	}
+	d, err := os.Stat(dst)
+	if err != nil {
+		return false, err
+	}
+	if d.IsDir() {
+		return false, nil
+	}
+	qs, qd := readQuality(src), readQuality(dst)
+	switch {
+	case qs.height > 0 && qd.height > 0 && qs.height != qd.height:
+		return qs.height > qd.height, nil
+	case qs.source > 0 && qd.source > 0 && qs.source != qd.source:
+		return qs.source > qd.source, nil
+	}
+	return s.Size() > d.Size(), nil
+}
+
+var (
+	heightRe = regexp.MustCompile(`(?i)(?:^|[^a-z\d])(\d{3,4})[pi](?:[^a-z\d]|$)`)
+	sourceRe = regexp.MustCompile(`(?i)(?:^|[^a-z\d])(hdtv|webrip|web-?dl|blu-?ray|remux)(?:[^a-z\d]|$)`)
+)
+
+// sources are the sources of release names from worst to best.
+var sources = []string{"hdtv", "webrip", "webdl", "bluray", "remux"}
+
+// A quality is the quality of a video file: its height in pixels and the
+// rank of its source in sources, each 0 if unknown.
+type quality struct {
+	height, source int
+}
+
+// readQuality returns the quality of the video file at path, read from tags in
+// its name like "1080p" and "BluRay" in "Show.S01E01.1080p.BluRay.mkv". If
+// the name has no resolution tag, as in names labeled like
+// "Series Name S01E01.mkv", the height is read from the file.
+func readQuality(path string) quality {
+	var q quality
+	name := filepath.Base(path)
+	if m := heightRe.FindStringSubmatch(name); m != nil {
+		q.height, _ = strconv.Atoi(m[1])
+	} else {
+		q.height = videoHeight(path)
+	}
+	if m := sourceRe.FindStringSubmatch(name); m != nil {
+		q.source = slices.Index(sources, strings.ReplaceAll(strings.ToLower(m[1]), "-", "")) + 1
+	}
+	return q
+}
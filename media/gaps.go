@@ -0,0 +1,150 @@
+// Copyright 2024 Matthew P. Dargan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package media
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// A GapReport lists the episodes missing from and duplicated in a season
+// directory.
+type GapReport struct {
+	Dir        string `json:"dir"`
+	Season     int    `json:"season"`
+	Missing    []int  `json:"missing,omitempty"`     // episodes missing before the last episode
+	Duplicates []int  `json:"duplicates,omitempty"`  // episodes held by more than one file
+	MissingEnd []int  `json:"missing_end,omitempty"` // aired episodes after the last episode
+}
+
+func (r GapReport) String() string {
+	var parts []string
+	if len(r.Missing) > 0 {
+		parts = append(parts, "missing "+episodeRanges(r.Missing))
+	}
+	if len(r.Duplicates) > 0 {
+		parts = append(parts, "duplicate "+episodeRanges(r.Duplicates))
+	}
+	if len(r.MissingEnd) > 0 {
+		parts = append(parts, "missing at end "+episodeRanges(r.MissingEnd))
+	}
+	return fmt.Sprintf("%s: %s", r.Dir, strings.Join(parts, "; "))
+}
+
+// episodeRanges formats sorted episode numbers ns like "E06, E09-E10".
+func episodeRanges(ns []int) string {
+	var b strings.Builder
+	for i := 0; i < len(ns); {
+		j := i
+		for j+1 < len(ns) && ns[j+1] == ns[j]+1 {
+			j++
+		}
+		if b.Len() > 0 {
+			b.WriteString(", ")
+		}
+		if j > i {
+			fmt.Fprintf(&b, "E%02d-E%02d", ns[i], ns[j])
+		} else {
+			fmt.Fprintf(&b, "E%02d", ns[i])
+		}
+		i = j + 1
+	}
+	return b.String()
+}
+
+// Gaps returns reports for the season directories in showDir that are
+// missing episodes or hold duplicate episodes, reading episode numbers from
+// names like "Series Name S01E01" or "Series Name S01E01-E02". If es, the
+// show's episodes from a [MetadataProvider], is not nil, episodes that aired
+// after the last episode in a season directory are reported missing too.
+func Gaps(showDir string, es []Episode) ([]GapReport, error) {
+	ents, err := os.ReadDir(showDir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid show directory: %w", err)
+	}
+	today := time.Now().Format(time.DateOnly)
+	var rs []GapReport
+	for _, ent := range ents {
+		if !ent.IsDir() {
+			continue
+		}
+		n, err := ParseSeasonDir(ent.Name())
+		if err != nil {
+			continue
+		}
+		dir := filepath.Join(showDir, ent.Name())
+		r, err := seasonGaps(dir, n)
+		if err != nil {
+			return nil, err
+		}
+		last := 0
+		for _, e := range r.held {
+			last = max(last, e)
+		}
+		for _, e := range es {
+			if e.Season == n && e.Number > last && e.Aired != "" && e.Aired <= today {
+				r.MissingEnd = append(r.MissingEnd, e.Number)
+			}
+		}
+		if len(r.Missing) > 0 || len(r.Duplicates) > 0 || len(r.MissingEnd) > 0 {
+			rs = append(rs, r.GapReport)
+		}
+	}
+	return rs, nil
+}
+
+// A seasonGap is a [GapReport] with the episodes the season directory holds.
+type seasonGap struct {
+	GapReport
+	held []int
+}
+
+// seasonGaps returns the gaps and duplicates in the directory at dir for
+// season n. Files holding different parts of an episode are not duplicates,
+// and episodes of other seasons are ignored.
+func seasonGaps(dir string, n int) (seasonGap, error) {
+	ents, err := os.ReadDir(dir)
+	if err != nil {
+		return seasonGap{}, err
+	}
+	parts := make(map[[2]int]int) // files holding each part of an episode
+	for _, ent := range ents {
+		if ent.IsDir() || !IsVideo(ent.Name()) {
+			continue
+		}
+		ef, err := parseEpisode(ent.Name())
+		if err != nil || ef.Season != n {
+			continue
+		}
+		for e := ef.Episode; e <= ef.Last; e++ {
+			parts[[2]int{e, ef.Part}]++
+		}
+	}
+	count := make(map[int]int) // most files holding a part of an episode
+	for k, c := range parts {
+		count[k[0]] = max(count[k[0]], c)
+	}
+	r := seasonGap{GapReport: GapReport{Dir: dir, Season: n}}
+	for e, c := range count {
+		r.held = append(r.held, e)
+		if c > 1 {
+			r.Duplicates = append(r.Duplicates, e)
+		}
+	}
+	slices.Sort(r.held)
+	slices.Sort(r.Duplicates)
+	if len(r.held) > 0 {
+		for e := 1; e < r.held[len(r.held)-1]; e++ {
+			if count[e] == 0 {
+				r.Missing = append(r.Missing, e)
+			}
+		}
+	}
+	return r, nil
+}
@@ -0,0 +1,251 @@
+// Copyright 2024 Matthew P. Dargan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package media
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// videoExts are the extensions of video files.
+var videoExts = []string{
+	".avi", ".m2ts", ".m4v", ".mkv", ".mov", ".mp4", ".mpeg", ".mpg",
+	".ts", ".webm", ".wmv",
+}
+
+// IsVideo reports whether the file at path is a video file.
+func IsVideo(path string) bool {
+	return slices.Contains(videoExts, strings.ToLower(filepath.Ext(path)))
+}
+
+// A Release is an episode identified by a scene or P2P release name.
+type Release struct {
+	Show    string // show name with separators replaced by spaces
+	Season  int
+	Episode int
+	Last    int // last episode in the file; equal to Episode for single episodes
+}
+
+var (
+	releaseRe = regexp.MustCompile(`(?i)^(.*?)(?:^|[ ._-]+)S(\d{1,2})[ ._-]?E(\d{1,3})((?:-?E\d{1,3})*)(?:\D|$)`)
+	crossRe   = regexp.MustCompile(`(?i)^(.*?)(?:^|[ ._-]+)(\d{1,2})x(\d{2,3})((?:-\d{1,2}x\d{2,3})*)(?:\D|$)`)
+	lastRe    = regexp.MustCompile(`\d+$`)
+	nameSepRe = regexp.MustCompile(`[._ ]+`)
+)
+
+// ParseRelease parses release names like "Show.Name.S03E07.1080p.WEB.mkv",
+// "Show Name - 3x07.mkv", and "Show.Name.S01E01E02.mkv".
+func ParseRelease(name string) (Release, error) {
+	m := releaseRe.FindStringSubmatch(name)
+	if m == nil {
+		m = crossRe.FindStringSubmatch(name)
+	}
+	if m == nil {
+		return Release{}, fmt.Errorf("release %q must contain season and episode", name)
+	}
+	var r Release
+	r.Show = strings.Trim(nameSepRe.ReplaceAllString(m[1], " "), " -")
+	r.Season, _ = strconv.Atoi(m[2])
+	r.Episode, _ = strconv.Atoi(m[3])
+	r.Last = r.Episode
+	if last := lastRe.FindString(m[4]); last != "" {
+		r.Last, _ = strconv.Atoi(last)
+	}
+	if r.Last < r.Episode {
+		return Release{}, fmt.Errorf("invalid episode range in %q", name)
+	}
+	return r, nil
+}
+
+// An Import represents downloaded episodes to categorize into a library of
+// show directories.
+type Import struct {
+	Library  string   // directory containing show directories
+	Paths    []string // episode files or directories containing them
+	Specials bool     // label new season 0 directories SpecialsDir
+}
+
+// ImportEpisodes adds episodes to the show directories in a library. It
+// parses the show, season, and episode numbers from release names with
+// [ParseRelease], then populates new season directories as in [MkSeason] and
+// adds to existing ones as in [AddEpisodes], keeping the episode numbers in
+// the release names.
+func ImportEpisodes(ctx context.Context, im Import, opts Options) (Result, error) {
+	p, err := PlanImport(im, opts)
+	if err != nil {
+		return Result{}, err
+	}
+	return p.Apply(ctx, opts)
+}
+
+// PlanImport returns the plan [ImportEpisodes] applies.
+func PlanImport(im Import, opts Options) (Plan, error) {
+	if !opts.Mode.IsPlacement() {
+		return Plan{}, fmt.Errorf("invalid placement %v", opts.Mode)
+	}
+	shows, err := showDirs(im.Library)
+	if err != nil {
+		return Plan{}, err
+	}
+	files, err := importFiles(im.Paths)
+	if err != nil {
+		return Plan{}, err
+	}
+	eps, sidecars, err := collectSidecars(files)
+	if err != nil {
+		return Plan{}, err
+	}
+	if len(eps) == 0 {
+		return Plan{}, errNoEpisodes
+	}
+	type key struct {
+		showDir string
+		n       int
+	}
+	seasons := make(map[key][]episode)
+	for _, e := range eps {
+		r, err := ParseRelease(filepath.Base(e))
+		if err != nil {
+			return Plan{}, err
+		}
+		showDir, err := matchShow(shows, r.Show)
+		if err != nil {
+			return Plan{}, err
+		}
+		k := key{showDir, r.Season}
+		seasons[k] = append(seasons[k], episode{path: e, n: r.Episode, last: r.Last})
+	}
+	keys := make([]key, 0, len(seasons))
+	for k := range seasons {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b key) int {
+		return cmp.Or(cmp.Compare(a.showDir, b.showDir), cmp.Compare(a.n, b.n))
+	})
+	var p Plan
+	for _, k := range keys {
+		es := seasons[k]
+		slices.SortStableFunc(es, func(a, b episode) int {
+			return cmp.Compare(a.n, b.n)
+		})
+		if es, err = number(es, 0, true); err != nil {
+			return Plan{}, err
+		}
+		show, err := ParseShowDir(filepath.Base(k.showDir))
+		if err != nil {
+			return Plan{}, err
+		}
+		seasonDir, ok := findSeasonDir(k.showDir, k.n)
+		if !ok {
+			seasonDir = filepath.Join(k.showDir, seasonDirName(k.n, im.Specials))
+			p.Ops = append(p.Ops, Op{Kind: Mkdir, Dst: seasonDir})
+		}
+		p.Ops = append(p.Ops, placeEpisodes(opts.Mode, opts.Names, show.Name, k.n, seasonDir, es, sidecars)...)
+	}
+	return resolve(p, opts.Conflict)
+}
+
+// showDirs returns the show directories in library.
+func showDirs(library string) ([]string, error) {
+	ents, err := os.ReadDir(library)
+	if err != nil {
+		return nil, fmt.Errorf("invalid library: %w", err)
+	}
+	var dirs []string
+	for _, ent := range ents {
+		if !ent.IsDir() {
+			continue
+		}
+		if _, err := ParseShowDir(ent.Name()); err == nil {
+			dirs = append(dirs, filepath.Join(library, ent.Name()))
+		}
+	}
+	return dirs, nil
+}
+
+// importFiles returns the files in paths and the video files in directories
+// in paths, skipping sample files. Sidecars beside the video files are found
+// by [collectSidecars].
+func importFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid episode: %w", err)
+		}
+		if !info.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		err = filepath.WalkDir(p, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if isSample(d.Name()) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.Type().IsRegular() && IsVideo(path) {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// isSample reports whether name is a release sample file or directory.
+func isSample(name string) bool {
+	name = strings.ToLower(name)
+	return name == "sample" || name == "samples" || strings.HasSuffix(stem(name), "sample")
+}
+
+// matchShow returns the show directory in dirs whose name matches show,
+// ignoring case, punctuation, and a trailing year.
+func matchShow(dirs []string, show string) (string, error) {
+	key := normalize(show)
+	var match string
+	for _, dir := range dirs {
+		s, err := ParseShowDir(filepath.Base(dir))
+		if err != nil {
+			continue
+		}
+		if key != normalize(s.Name) && key != normalize(s.Name+s.Year) {
+			continue
+		}
+		if match != "" {
+			return "", fmt.Errorf("show %q matches %q and %q", show, match, dir)
+		}
+		match = dir
+	}
+	if match == "" {
+		return "", fmt.Errorf("no show directory for %q", show)
+	}
+	return match, nil
+}
+
+// normalize returns s in lower case without spaces or punctuation.
+func normalize(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, s)
+}
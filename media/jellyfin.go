@@ -0,0 +1,147 @@
+// Copyright 2024 Matthew P. Dargan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package media
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// A Jellyfin is a client for the library API of a Jellyfin server.
+type Jellyfin struct {
+	URL    string       // server URL, like "http://localhost:8096"
+	Key    string       // API key
+	Client *http.Client // defaults to http.DefaultClient
+}
+
+// A MediaUpdate reports a path created, modified, or deleted in a library.
+type MediaUpdate struct {
+	Path       string
+	UpdateType string // "Created", "Modified", or "Deleted"
+}
+
+// RefreshLibrary asks the server to scan every library.
+func (j *Jellyfin) RefreshLibrary(ctx context.Context) error {
+	return j.post(ctx, "/Library/Refresh", nil)
+}
+
+// Updated asks the server to scan only the paths in us.
+func (j *Jellyfin) Updated(ctx context.Context, us []MediaUpdate) error {
+	if len(us) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(struct{ Updates []MediaUpdate }{us})
+	if err != nil {
+		return err
+	}
+	return j.post(ctx, "/Library/Media/Updated", body)
+}
+
+// Refresh asks the server to scan the library paths affected by applying p:
+// the directories p created, the files it placed, and the files it moved or
+// removed. Paths outside libraries, like the downloads episodes were moved
+// from, are not reported, and paths inside directories p created are covered
+// by the directory.
+func (j *Jellyfin) Refresh(ctx context.Context, p Plan) error {
+	return j.Updated(ctx, Updates(p))
+}
+
+// Updates returns the updates to report to a Jellyfin server for the library
+// paths affected by applying p, as in [Jellyfin.Refresh]. A path is in a
+// library if it or a directory holding it is labeled with a year or provider
+// ID, like "Series Name (2018) [tvdbid-65567]". Relative paths are made
+// absolute.
+func Updates(p Plan) []MediaUpdate {
+	created := make(map[string]bool)
+	for _, o := range p.Ops {
+		if o.Kind == Mkdir && inLibrary(abs(o.Dst)) {
+			created[abs(o.Dst)] = true
+		}
+	}
+	var us []MediaUpdate
+	seen := make(map[MediaUpdate]bool)
+	add := func(path, typ string) {
+		path = abs(path)
+		if !inLibrary(path) || isBackup(filepath.Base(path)) {
+			return
+		}
+		for d := filepath.Dir(path); d != filepath.Dir(d); d = filepath.Dir(d) {
+			if created[d] {
+				return
+			}
+		}
+		u := MediaUpdate{Path: path, UpdateType: typ}
+		if !seen[u] {
+			seen[u] = true
+			us = append(us, u)
+		}
+	}
+	for _, o := range p.Ops {
+		switch o.Kind {
+		case Remove:
+			add(o.Dst, "Deleted")
+		case Rename:
+			add(o.Src, "Deleted")
+			add(o.Dst, "Created")
+		default:
+			add(o.Dst, "Created")
+		}
+	}
+	return us
+}
+
+// inLibrary reports whether path or a directory holding it is labeled with a
+// year or provider ID like show directories and movies in a library.
+func inLibrary(path string) bool {
+	for ; path != filepath.Dir(path); path = filepath.Dir(path) {
+		name := filepath.Base(path)
+		for _, n := range []string{name, strings.TrimSuffix(name, filepath.Ext(name))} {
+			if s, err := parseLabeled(n); err == nil && s.Year+s.IMDBID+s.TMDBID+s.TVDBID != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// abs returns the absolute form of path, or path cleaned if it has none.
+func abs(path string) string {
+	if a, err := filepath.Abs(path); err == nil {
+		return a
+	}
+	return filepath.Clean(path)
+}
+
+// post sends a POST request for path with JSON body to the server.
+func (j *Jellyfin) post(ctx context.Context, path string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(j.URL, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("MediaBrowser Token=%q", j.Key))
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	c := j.Client
+	if c == nil {
+		c = http.DefaultClient
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return &APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(msg))}
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+// Copyright 2024 Matthew P. Dargan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package media
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// A Journal is an append-only log of applied plans. Each line of the file at
+// Path is a JSON-encoded [Entry].
+type Journal struct {
+	Path string
+}
+
+// An Entry records the operations applied by a plan.
+type Entry struct {
+	ID   int       `json:"id"`
+	Time time.Time `json:"time"`
+	Ops  []Op      `json:"ops"`
+	Undo int       `json:"undo,omitempty"` // ID of the entry this entry reverses
+
+	UndoneBy int `json:"-"` // ID of the entry that reverses this entry, set by [Journal.Entries]
+}
+
+// Inverse returns the plan that reverses e.
+func (e Entry) Inverse() (Plan, error) {
+	var p Plan
+	for i := len(e.Ops) - 1; i >= 0; i-- {
+		inv, err := e.Ops[i].inverse()
+		if err != nil {
+			return Plan{}, err
+		}
+		p.Ops = append(p.Ops, inv...)
+	}
+	return p, nil
+}
+
+var errNoEntry = errors.New("no journal entry to undo")
+
+// Entries returns the entries in j in the order they were recorded.
+func (j Journal) Entries() ([]Entry, error) {
+	f, err := os.Open(j.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	es, err := readEntries(f)
+	if err != nil {
+		return nil, err
+	}
+	undone := make(map[int]int)
+	for _, e := range es {
+		if e.Undo != 0 {
+			undone[e.Undo] = e.ID
+		}
+	}
+	for i := range es {
+		es[i].UndoneBy = undone[es[i].ID]
+	}
+	return es, nil
+}
+
+// readEntries returns the entries read from r.
+func readEntries(r io.Reader) ([]Entry, error) {
+	var es []Entry
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<24)
+	for sc.Scan() {
+		var e Entry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("invalid journal entry: %w", err)
+		}
+		es = append(es, e)
+	}
+	return es, sc.Err()
+}
+
+// Undoable returns the entry with the given ID, or the most recent entry if id
+// is 0. It reports an error if the entry is an undo or was already undone.
+func (j Journal) Undoable(id int) (Entry, error) {
+	es, err := j.Entries()
+	if err != nil {
+		return Entry{}, err
+	}
+	for i := len(es) - 1; i >= 0; i-- {
+		e := es[i]
+		switch {
+		case id != 0 && e.ID != id:
+			continue
+		case e.Undo != 0 || e.UndoneBy != 0:
+			if id != 0 {
+				return Entry{}, fmt.Errorf("journal entry %d cannot be undone", id)
+			}
+			continue
+		}
+		return e, nil
+	}
+	if id != 0 {
+		return Entry{}, fmt.Errorf("no journal entry %d", id)
+	}
+	return Entry{}, errNoEntry
+}
+
+// Apply applies p and records its operations. If ctx is canceled, the
+// operations that completed are recorded, and if p fails and some of its
+// operations cannot be rolled back, those operations are recorded, so that
+// they can be undone. Paths are recorded as absolute paths. p is applied as
+// in [Plan.Apply].
+func (j Journal) Apply(ctx context.Context, p Plan, opts Options) (Entry, error) {
+	return j.apply(ctx, p, opts, 0)
+}
+
+// Undo applies p, the [Entry.Inverse] of the entry with the given ID, and
+// records it as an undo of that entry. An undo that is interrupted or fails
+// partway is recorded as an ordinary entry instead, so that it can itself be
+// undone and the entry with the given ID undone again.
+func (j Journal) Undo(ctx context.Context, p Plan, id int, opts Options) (Entry, error) {
+	return j.apply(ctx, p, opts, id)
+}
+
+func (j Journal) apply(ctx context.Context, p Plan, opts Options, undo int) (Entry, error) {
+	e := Entry{Undo: undo}
+	ops, err := p.apply(ctx, opts)
+	if err != nil {
+		e.Undo = 0
+	}
+	for _, o := range ops {
+		e.Ops = append(e.Ops, absOp(o))
+	}
+	if len(e.Ops) == 0 {
+		return e, err
+	}
+	e.Time = time.Now()
+	id, aerr := j.append(e)
+	e.ID = id
+	return e, errors.Join(err, aerr)
+}
+
+// append records e in j with the ID after the last entry's and returns the
+// ID. The journal is locked while the ID is chosen and e is written, so
+// processes sharing j, like `epify watch` and manual commands, never record
+// the same ID.
+func (j Journal) append(e Entry) (int, error) {
+	if err := os.MkdirAll(filepath.Dir(j.Path), 0o755); err != nil {
+		return 0, err
+	}
+	f, err := os.OpenFile(j.Path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	// Closing f releases the lock.
+	if err = lockFile(f); err != nil {
+		return 0, err
+	}
+	es, err := readEntries(f)
+	if err != nil {
+		return 0, err
+	}
+	e.ID = 1
+	if len(es) > 0 {
+		e.ID = es[len(es)-1].ID + 1
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return 0, err
+	}
+	if _, err = f.Write(append(b, '\n')); err != nil {
+		return 0, err
+	}
+	return e.ID, f.Close()
+}
+
+func absOp(o Op) Op {
+	if o.Src != "" {
+		if src, err := filepath.Abs(o.Src); err == nil {
+			o.Src = src
+		}
+	}
+	if dst, err := filepath.Abs(o.Dst); err == nil {
+		o.Dst = dst
+	}
+	return o
+}
@@ -0,0 +1,14 @@
+// Copyright 2024 Matthew P. Dargan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !unix
+
+package media
+
+import "os"
+
+// lockFile does nothing on systems without flock.
+func lockFile(_ *os.File) error {
+	return nil
+}
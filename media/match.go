@@ -0,0 +1,106 @@
+// Copyright 2024 Matthew P. Dargan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package media
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// A Matcher reads episode numbers from filenames with a regular expression.
+// The named group "episode" captures the episode number, and the optional
+// groups "season" and "part" capture the season number and the part of an
+// episode split across files. Files whose season differs from the season
+// being labeled are rejected, and parts of an episode share its number. The
+// zero Matcher matches nothing.
+type Matcher struct {
+	expr string // preset name or regular expression
+	re   *regexp.Regexp
+}
+
+// matcherPresets are the regular expressions of the named matchers.
+var matcherPresets = map[string]string{
+	"sxxeyy":  `(?i)S(?P<season>\d{1,3})[ ._-]?E(?P<episode>\d{1,4})`,
+	"nxnn":    `(?i)(?:^|[^\da-z])(?P<season>\d{1,2})x(?P<episode>\d{2,3})(?:\D|$)`,
+	"episode": `(?i)(?:^|[^a-z])Ep(?:isode)?[ ._-]*(?P<episode>\d{1,4})`,
+}
+
+// ParseMatcher returns the Matcher for s, the name of a preset or a regular
+// expression with an "episode" group. The presets are "sxxeyy" for names like
+// "Show.S01E05.mkv", "nxnn" for names like "Show 1x05.mkv", and "episode" for
+// names like "Show Episode 5.mkv" or "Show Ep05.mkv".
+func ParseMatcher(s string) (Matcher, error) {
+	expr := s
+	if p, ok := matcherPresets[s]; ok {
+		expr = p
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return Matcher{}, fmt.Errorf("invalid matcher: %w", err)
+	}
+	if re.SubexpIndex("episode") < 0 {
+		return Matcher{}, fmt.Errorf("matcher %q has no episode group", s)
+	}
+	return Matcher{expr: s, re: re}, nil
+}
+
+// String returns the preset name or regular expression of m.
+func (m Matcher) String() string {
+	return m.expr
+}
+
+// IsZero reports whether m is the zero Matcher.
+func (m Matcher) IsZero() bool {
+	return m.re == nil
+}
+
+// MarshalText implements [encoding.TextMarshaler].
+func (m Matcher) MarshalText() ([]byte, error) {
+	return []byte(m.expr), nil
+}
+
+// UnmarshalText implements [encoding.TextUnmarshaler].
+func (m *Matcher) UnmarshalText(b []byte) error {
+	v, err := ParseMatcher(string(b))
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+}
+
+// match returns the episode m reads from name, which must be in season n if
+// m reads a season, and the index in name of the start and end of its episode
+// number. m must not be zero.
+func (m Matcher) match(name string, n int) (e episode, start, end int, err error) {
+	g := m.re.FindStringSubmatchIndex(name)
+	k := m.re.SubexpIndex("episode")
+	if g == nil || g[2*k] < 0 {
+		return episode{}, 0, 0, fmt.Errorf("episode %q does not match %q", name, m.expr)
+	}
+	start, end = g[2*k], g[2*k+1]
+	if e.n, err = strconv.Atoi(name[start:end]); err != nil {
+		return episode{}, 0, 0, fmt.Errorf("invalid episode number: %w", err)
+	}
+	e.last = e.n
+	season := n
+	for _, f := range []struct {
+		group string
+		n     *int
+	}{{"season", &season}, {"part", &e.part}} {
+		k := m.re.SubexpIndex(f.group)
+		if k < 0 || g[2*k] < 0 {
+			continue
+		}
+		if *f.n, err = strconv.Atoi(name[g[2*k]:g[2*k+1]]); err != nil {
+			return episode{}, 0, 0, fmt.Errorf("invalid %s number: %w", f.group, err)
+		}
+	}
+	if season != n {
+		return episode{}, 0, 0, fmt.Errorf("episode %q is in season %d, not season %d", name, season, n)
+	}
+	return e, start, end, nil
+}
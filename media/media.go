@@ -0,0 +1,598 @@
+// Copyright 2024 Matthew P. Dargan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package media provides facilities for categorizing [shows] and [movies]
+// using the Jellyfin naming scheme.
+//
+// [shows]: https://jellyfin.org/docs/general/server/media/shows/
+// [movies]: https://jellyfin.org/docs/general/server/media/movies/
+package media
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// A Show represents a TV show. ID is the show's ID at the provider it is
+// looked up with: TVDB for shows and TMDB for movies. A show may be labeled
+// with the IDs of other providers too.
+type Show struct {
+	Name, Year, ID, Dir    string
+	TVDBID, TMDBID, IMDBID string // provider IDs; ID is used if the field for its provider is empty
+}
+
+// providerIDs are the tags labeling provider IDs, in the order they appear in
+// names, along with the names of their fields and the IDs they accept.
+var providerIDs = []struct {
+	tag, field string
+	re         *regexp.Regexp
+}{
+	{"imdbid", "IMDBID", regexp.MustCompile(`^tt\d+$`)},
+	{"tmdbid", "TMDBID", regexp.MustCompile(`^\d+$`)},
+	{"tvdbid", "TVDBID", regexp.MustCompile(`^\d+$`)},
+}
+
+// idTags returns the tags labeling the provider IDs of s, like
+// " [imdbid-tt0112573] [tmdbid-197]", with s.ID as the ID for the provider
+// labeled def if s has none for it.
+func (s Show) idTags(def string) (string, error) {
+	ids := map[string]string{"imdbid": s.IMDBID, "tmdbid": s.TMDBID, "tvdbid": s.TVDBID}
+	if ids[def] == "" {
+		ids[def] = s.ID
+	}
+	var b strings.Builder
+	for _, p := range providerIDs {
+		id := ids[p.tag]
+		if id == "" {
+			continue
+		}
+		if !p.re.MatchString(id) {
+			return "", fmt.Errorf("invalid %s %q", p.field, id)
+		}
+		fmt.Fprintf(&b, " [%s-%s]", p.tag, id)
+	}
+	if b.Len() == 0 {
+		return "", errors.New("missing provider ID")
+	}
+	return b.String(), nil
+}
+
+// MkShow creates a show directory. The directory will be labeled like
+// "Series Name (2018) [tvdbid-65567]", with the name sanitized by opts.Names,
+// or like "Series Name [tvdbid-65567]" if s.Year is empty.
+// Shows with IDs at other providers are labeled like
+// "Series Name (2018) [imdbid-tt0903747] [tvdbid-81189]", and s.ID is taken
+// as the TVDB ID.
+func MkShow(ctx context.Context, s Show, opts Options) (Result, error) {
+	p, err := PlanShow(s, opts)
+	if err != nil {
+		return Result{}, err
+	}
+	return p.Apply(ctx, opts)
+}
+
+// PlanShow returns the plan [MkShow] applies. The plan creates the show
+// directory and any missing parent directories.
+func PlanShow(s Show, opts Options) (Plan, error) {
+	if len(s.Name) == 0 {
+		return Plan{}, errors.New("empty show name")
+	}
+	var tags string
+	if s.Year != "" {
+		year, err := strconv.Atoi(s.Year)
+		if err != nil {
+			return Plan{}, fmt.Errorf("invalid year: %w", err)
+		}
+		tags = fmt.Sprintf(" (%d)", year)
+	}
+	ids, err := s.idTags("tvdbid")
+	if err != nil {
+		return Plan{}, err
+	}
+	tags += ids
+	name := opts.Names.fit(s.Name, tags)
+	if len(name) == 0 {
+		return Plan{}, fmt.Errorf("invalid show name %q", s.Name)
+	}
+	var p Plan
+	path := name + tags
+	for d := filepath.Join(s.Dir, path); ; {
+		info, err := os.Stat(d)
+		if err == nil {
+			if !info.IsDir() {
+				return Plan{}, fmt.Errorf("%q is not a directory", d)
+			}
+			break
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return Plan{}, err
+		}
+		p.Ops = append(p.Ops, Op{Kind: Mkdir, Dst: d})
+		parent := filepath.Dir(d)
+		if parent == d {
+			break
+		}
+		d = parent
+	}
+	slices.Reverse(p.Ops)
+	return p, nil
+}
+
+// A Movie represents a movie.
+type Movie struct {
+	Show
+	File string
+}
+
+// AddMovie adds a movie to a directory. Movies are labeled like
+// "Film (2018) [tmdbid-65567]", with the name sanitized by opts.Names. Movies
+// with IDs at other providers are labeled like
+// "Film (2018) [imdbid-tt0112573] [tmdbid-197]", and m.ID is taken as the
+// TMDB ID. The movie is moved unless opts.Mode specifies another placement.
+// An existing movie with the same name is never overwritten unless
+// opts.Conflict allows replacing it.
+func AddMovie(ctx context.Context, m Movie, opts Options) (Result, error) {
+	p, err := PlanMovie(m, opts)
+	if err != nil {
+		return Result{}, err
+	}
+	return p.Apply(ctx, opts)
+}
+
+// PlanMovie returns the plan [AddMovie] applies.
+func PlanMovie(m Movie, opts Options) (Plan, error) {
+	if len(m.Name) == 0 {
+		return Plan{}, errors.New("empty movie name")
+	}
+	if !opts.Mode.IsPlacement() {
+		return Plan{}, fmt.Errorf("invalid placement %v", opts.Mode)
+	}
+	year, err := strconv.Atoi(m.Year)
+	if err != nil {
+		return Plan{}, fmt.Errorf("invalid year: %w", err)
+	}
+	ids, err := m.idTags("tmdbid")
+	if err != nil {
+		return Plan{}, err
+	}
+	info, err := os.Stat(m.Dir)
+	if err != nil {
+		return Plan{}, fmt.Errorf("invalid directory: %w", err)
+	}
+	if !info.IsDir() {
+		return Plan{}, fmt.Errorf("%q is not a directory", m.Dir)
+	}
+	info, err = os.Stat(m.File)
+	if err != nil {
+		return Plan{}, fmt.Errorf("invalid movie: %w", err)
+	}
+	if info.IsDir() {
+		return Plan{}, fmt.Errorf("%q is a directory", m.File)
+	}
+	tags := fmt.Sprintf(" (%d)%s%s", year, ids, filepath.Ext(m.File))
+	name := opts.Names.fit(m.Name, tags)
+	if len(name) == 0 {
+		return Plan{}, fmt.Errorf("invalid movie name %q", m.Name)
+	}
+	return resolve(Plan{Ops: []Op{{Kind: opts.Mode, Src: m.File, Dst: filepath.Join(m.Dir, name+tags)}}}, opts.Conflict)
+}
+
+// A Season represents a TV show season.
+type Season struct {
+	N          string // season number
+	Specials   bool   // label season 0 SpecialsDir instead of "Season 00"
+	ShowDir    string
+	Episodes   []string
+	MatchIndex int     // index of the episode number in filenames
+	Match      Matcher // reads episode numbers from filenames; MatchIndex is used if zero
+	Preserve   bool    // number episodes by the numbers in filenames instead of position
+}
+
+var errNoEpisodes = errors.New("no episodes found")
+
+// YearSep separates the show name from the year.
+//
+// Deprecated: Show names may hold YearSep. Use [ParseShowDir] to read show
+// directory names.
+const YearSep = " ("
+
+// SpecialsDir is the alternative name for the season 0 directory.
+const SpecialsDir = "Specials"
+
+// seasonDirName returns the name of the directory for season n.
+func seasonDirName(n int, specials bool) string {
+	if specials && n == 0 {
+		return SpecialsDir
+	}
+	return fmt.Sprintf("Season %02d", n)
+}
+
+// MkSeason creates a season directory and moves episodes into it. Episodes are
+// labeled like "Series Name S01E01.mkv". Subtitle, audio, and metadata files
+// named after an episode, like "ep01.en.srt", follow the episode and keep
+// their suffixes, like "Series Name S01E01.en.srt". Files holding multiple
+// episodes, like "ep01-02.mkv", are labeled like "Series Name S01E01-E02.mkv",
+// and parts of an episode s.Match reads share its number, like
+// "Series Name S01E01-part1.mkv". If s.Preserve is set, episodes keep the
+// numbers in their filenames instead of being numbered in order. Season 0
+// holds specials, and its directory is labeled [SpecialsDir] if s.Specials is
+// set. Episodes are moved unless opts.Mode specifies another placement, and
+// existing episodes are never overwritten unless opts.Conflict allows
+// replacing them.
+func MkSeason(ctx context.Context, s Season, opts Options) (Result, error) {
+	p, err := PlanSeason(s, opts)
+	if err != nil {
+		return Result{}, err
+	}
+	return p.Apply(ctx, opts)
+}
+
+// PlanSeason returns the plan [MkSeason] applies.
+func PlanSeason(s Season, opts Options) (Plan, error) {
+	n, err := strconv.Atoi(s.N)
+	if err != nil {
+		return Plan{}, fmt.Errorf("invalid season: %w", err)
+	}
+	if !opts.Mode.IsPlacement() {
+		return Plan{}, fmt.Errorf("invalid placement %v", opts.Mode)
+	}
+	if s.Specials && n != 0 {
+		return Plan{}, fmt.Errorf("season %d cannot be specials", n)
+	}
+	info, err := os.Stat(s.ShowDir)
+	if err != nil {
+		return Plan{}, fmt.Errorf("invalid directory: %w", err)
+	}
+	if !info.IsDir() {
+		return Plan{}, fmt.Errorf("%q is not a directory", s.ShowDir)
+	}
+	show, err := ParseShowDir(filepath.Base(s.ShowDir))
+	if err != nil {
+		return Plan{}, err
+	}
+	if len(s.Episodes) == 0 {
+		return Plan{}, errNoEpisodes
+	}
+	for _, e := range s.Episodes {
+		info, err = os.Stat(e)
+		if err != nil {
+			return Plan{}, fmt.Errorf("invalid episode: %w", err)
+		}
+		if info.IsDir() {
+			return Plan{}, fmt.Errorf("%q is a directory", e)
+		}
+	}
+	eps, sidecars, err := collectSidecars(s.Episodes)
+	if err != nil {
+		return Plan{}, err
+	}
+	if len(eps) == 0 {
+		return Plan{}, errNoEpisodes
+	}
+	es, err := sortEpisodes(eps, n, s.Match, s.MatchIndex)
+	if err != nil {
+		return Plan{}, err
+	}
+	ns, err := number(es, 1, s.Preserve)
+	if err != nil {
+		return Plan{}, err
+	}
+	if dir, ok := findSeasonDir(s.ShowDir, n); ok {
+		return Plan{}, fmt.Errorf("%q already exists", dir)
+	}
+	seasonDir := filepath.Join(s.ShowDir, seasonDirName(n, s.Specials))
+	p := Plan{Ops: []Op{{Kind: Mkdir, Dst: seasonDir}}}
+	p.Ops = append(p.Ops, placeEpisodes(opts.Mode, opts.Names, show.Name, n, seasonDir, ns, sidecars)...)
+	return resolve(p, opts.Conflict)
+}
+
+// findSeasonDir returns the existing directory for season n in showDir.
+func findSeasonDir(showDir string, n int) (string, bool) {
+	for _, specials := range []bool{false, true} {
+		if specials && n != 0 {
+			break
+		}
+		dir := filepath.Join(showDir, seasonDirName(n, specials))
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir, true
+		}
+	}
+	return "", false
+}
+
+// placeEpisodes returns the operations that place numbered episodes es of
+// season n, along with their sidecars, in seasonDir. The show name is
+// shortened to fit the longest name names allows.
+func placeEpisodes(kind OpKind, names NameProfile, show string, n int, seasonDir string, es []episode, sidecars map[string][]string) []Op {
+	var ops []Op
+	for _, e := range es {
+		tag := episodeName("", n, e)
+		suffix := filepath.Ext(e.path)
+		for _, sc := range sidecars[e.path] {
+			if s := strings.TrimPrefix(filepath.Base(sc), stem(e.path)); len(s) > len(suffix) {
+				suffix = s
+			}
+		}
+		name := names.fit(show, tag+suffix) + tag
+		ops = append(ops, place(kind, e.path, sidecars[e.path], seasonDir, name)...)
+	}
+	return ops
+}
+
+// An Addition represents episodes to add to a season.
+type Addition struct {
+	SeasonDir  string
+	Episodes   []string
+	MatchIndex int     // index of the episode number in filenames
+	Match      Matcher // reads episode numbers from filenames; MatchIndex is used if zero
+	Preserve   bool    // number episodes by the numbers in filenames instead of position
+	FillGaps   bool    // number episodes into gaps before the last episode first
+}
+
+// AddEpisodes adds episodes to a season directory, which may be labeled like
+// "Season 01" or [SpecialsDir] for season 0. Episode numbers continue after
+// the highest episode in the season directory, counting the last episode of
+// files holding multiple episodes, unless a.Preserve is set. If a.FillGaps
+// is set, episodes are numbered into the gaps before the highest episode
+// first. Only video files labeled like "Series Name S01E01" or
+// "Series Name S01E01 - Title" with the directory's season count as episodes
+// in the season directory; other videos like samples and episodes of other
+// seasons, subtitles, metadata, artwork, and directories like "Extras" are
+// ignored. Sidecar files follow their episode as in [MkSeason]. Existing episodes are never overwritten unless
+// opts.Conflict allows replacing them.
+func AddEpisodes(ctx context.Context, a Addition, opts Options) (Result, error) {
+	p, err := PlanAddition(a, opts)
+	if err != nil {
+		return Result{}, err
+	}
+	return p.Apply(ctx, opts)
+}
+
+// PlanAddition returns the plan [AddEpisodes] applies.
+func PlanAddition(a Addition, opts Options) (Plan, error) {
+	if !opts.Mode.IsPlacement() {
+		return Plan{}, fmt.Errorf("invalid placement %v", opts.Mode)
+	}
+	info, err := os.Stat(a.SeasonDir)
+	if err != nil {
+		return Plan{}, fmt.Errorf("invalid season directory: %w", err)
+	}
+	if !info.IsDir() {
+		return Plan{}, fmt.Errorf("%q is not a directory", a.SeasonDir)
+	}
+	n, err := ParseSeasonDir(filepath.Base(a.SeasonDir))
+	if err != nil {
+		return Plan{}, err
+	}
+	showDir := filepath.Dir(a.SeasonDir)
+	show, err := ParseShowDir(filepath.Base(showDir))
+	if err != nil {
+		return Plan{}, err
+	}
+	if len(a.Episodes) == 0 {
+		return Plan{}, errNoEpisodes
+	}
+	for _, e := range a.Episodes {
+		info, err = os.Stat(e)
+		if err != nil {
+			return Plan{}, fmt.Errorf("invalid episode: %w", err)
+		}
+		if info.IsDir() {
+			return Plan{}, fmt.Errorf("%q is a directory", e)
+		}
+	}
+	eps, sidecars, err := collectSidecars(a.Episodes)
+	if err != nil {
+		return Plan{}, err
+	}
+	if len(eps) == 0 {
+		return Plan{}, errNoEpisodes
+	}
+	es, err := sortEpisodes(eps, n, a.Match, a.MatchIndex)
+	if err != nil {
+		return Plan{}, err
+	}
+	var ns []episode
+	switch {
+	case a.Preserve && a.FillGaps:
+		return Plan{}, errors.New("cannot both preserve episode numbers and fill gaps")
+	case a.Preserve:
+		ns, err = number(es, 1, true)
+	default:
+		held, last, herr := heldEpisodes(a.SeasonDir, n)
+		if herr != nil {
+			return Plan{}, herr
+		}
+		if a.FillGaps {
+			ns = fillGaps(es, held)
+		} else {
+			ns, err = number(es, last+1, false)
+		}
+	}
+	if err != nil {
+		return Plan{}, err
+	}
+	return resolve(Plan{Ops: placeEpisodes(opts.Mode, opts.Names, show.Name, n, a.SeasonDir, ns, sidecars)}, opts.Conflict)
+}
+
+// heldEpisodes returns the episode numbers held by the video files in
+// seasonDir, the directory for season n, and the highest of them. Video files
+// not labeled like episodes of season n, like samples, are ignored.
+func heldEpisodes(seasonDir string, n int) (map[int]bool, int, error) {
+	ents, err := os.ReadDir(seasonDir)
+	if err != nil {
+		return nil, 0, err
+	}
+	held := make(map[int]bool)
+	var last int
+	for _, ent := range ents {
+		if ent.IsDir() || !IsVideo(ent.Name()) {
+			continue
+		}
+		e, err := parseEpisode(ent.Name())
+		if err != nil || e.Season != n {
+			continue
+		}
+		for i := e.Episode; i <= e.Last; i++ {
+			held[i] = true
+		}
+		last = max(last, e.Last)
+	}
+	return held, last, nil
+}
+
+// fillGaps numbers es in order into the first runs of episode numbers not in
+// held that fit them, continuing after the highest held episode. Parts of an
+// episode share its number.
+func fillGaps(es []episode, held map[int]bool) []episode {
+	ns := make([]episode, len(es))
+	n := 1
+	for i, e := range es {
+		if i > 0 && samePart(es[i-1], e) {
+			ns[i] = episode{path: e.path, n: ns[i-1].n, last: ns[i-1].last, part: e.part}
+			continue
+		}
+		span := e.last - e.n
+		for !free(held, n, n+span) {
+			n++
+		}
+		ns[i] = episode{path: e.path, n: n, last: n + span, part: e.part}
+		n += span + 1
+	}
+	return ns
+}
+
+// free reports whether no episode from n through last is in held.
+func free(held map[int]bool, n, last int) bool {
+	for ; n <= last; n++ {
+		if held[n] {
+			return false
+		}
+	}
+	return true
+}
+
+var (
+	re       = regexp.MustCompile(`\d+`)
+	multiRe  = regexp.MustCompile(`^-(\d{1,3})\b`)
+	multiERe = regexp.MustCompile(`^(?:-?[Ee]|-)(\d{1,3})\b`)
+)
+
+// An episode is a source episode file holding episodes n through last, or
+// part of episode n if part is positive.
+type episode struct {
+	path          string
+	n, last, part int
+}
+
+// sortEpisodes sorts eps of season n by the episode and part numbers m reads
+// from their filenames, or by the number at match index i if m is zero. A number
+// followed by more numbers like "01-02" or "E01E02" marks a file holding
+// multiple episodes. The "E" form requires the number itself to follow "E",
+// so "S01E02" is not read as episodes 1 through 2.
+func sortEpisodes(eps []string, n int, m Matcher, i int) ([]episode, error) {
+	if m.IsZero() && i < 0 {
+		return nil, fmt.Errorf("invalid match index %d", i)
+	}
+	es := make([]episode, len(eps))
+	for j, e := range eps {
+		base := filepath.Base(e)
+		ns := re.FindAllStringIndex(base, -1)
+		if len(ns) == 0 {
+			return nil, fmt.Errorf("episode %q must contain number", e)
+		}
+		var (
+			ep         episode
+			start, end int
+			err        error
+		)
+		if m.IsZero() {
+			if i >= len(ns) {
+				return nil, fmt.Errorf("invalid match index %d", i)
+			}
+			start, end = ns[i][0], ns[i][1]
+			if ep.n, err = strconv.Atoi(base[start:end]); err != nil {
+				return nil, fmt.Errorf("invalid episode number: %w", err)
+			}
+			ep.last = ep.n
+		} else if ep, start, end, err = m.match(base, n); err != nil {
+			return nil, err
+		}
+		ep.path = e
+		es[j] = ep
+		mre := multiRe
+		if start > 0 && (base[start-1] == 'E' || base[start-1] == 'e') {
+			mre = multiERe
+		}
+		for rest := base[end:]; ; {
+			mm := mre.FindStringSubmatchIndex(rest)
+			if mm == nil {
+				break
+			}
+			last, _ := strconv.Atoi(rest[mm[2]:mm[3]])
+			if last <= es[j].last {
+				break
+			}
+			es[j].last = last
+			rest = rest[mm[1]:]
+		}
+	}
+	slices.SortStableFunc(es, func(a, b episode) int {
+		return cmp.Or(cmp.Compare(a.n, b.n), cmp.Compare(a.part, b.part))
+	})
+	return es, nil
+}
+
+// number returns the episode numbers for es. If preserve is set, episodes
+// keep the numbers in their filenames. Otherwise, they are numbered in order
+// starting at start, and files holding multiple episodes advance the number
+// by the episodes they hold. Parts of an episode share its number.
+func number(es []episode, start int, preserve bool) ([]episode, error) {
+	ns := make([]episode, len(es))
+	for i, e := range es {
+		if i > 0 && samePart(es[i-1], e) {
+			ns[i] = episode{path: e.path, n: ns[i-1].n, last: ns[i-1].last, part: e.part}
+			continue
+		}
+		if !preserve {
+			ns[i] = episode{path: e.path, n: start, last: start + e.last - e.n, part: e.part}
+			start = ns[i].last + 1
+			continue
+		}
+		if i > 0 && e.n <= es[i-1].last {
+			return nil, fmt.Errorf("duplicate episode %d: %q and %q", e.n, es[i-1].path, e.path)
+		}
+		ns[i] = e
+	}
+	return ns, nil
+}
+
+// samePart reports whether sorted episodes a and b are parts of the same
+// episode.
+func samePart(a, b episode) bool {
+	return a.part > 0 && b.part > a.part && a.n == b.n && a.last == b.last
+}
+
+// episodeName returns the name of episode e in season n of show, like
+// "Series Name S01E01", "Series Name S01E01-E02", or
+// "Series Name S01E01-part1".
+func episodeName(show string, n int, e episode) string {
+	name := fmt.Sprintf("%s S%02dE%02d", show, n, e.n)
+	if e.last > e.n {
+		name += fmt.Sprintf("-E%02d", e.last)
+	}
+	if e.part > 0 {
+		name += fmt.Sprintf("-part%d", e.part)
+	}
+	return name
+}
@@ -0,0 +1,150 @@
+// Copyright 2024 Matthew P. Dargan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package media
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+)
+
+// A MetadataProvider searches a metadata database for shows and movies.
+type MetadataProvider interface {
+	// SearchShows returns the shows named like name, best match first.
+	// The shows have Name, Year, and ID set.
+	SearchShows(ctx context.Context, name string) ([]Show, error)
+
+	// SearchMovies returns the movies named like name, best match first.
+	// The movies have Name, Year, and ID set.
+	SearchMovies(ctx context.Context, name string) ([]Movie, error)
+
+	// Episodes returns the episodes of the show with the given ID, ordered
+	// by season and episode number.
+	Episodes(ctx context.Context, id string) ([]Episode, error)
+}
+
+// An Episode is a show episode known to a [MetadataProvider].
+type Episode struct {
+	Season int    `json:"season"`
+	Number int    `json:"number"`
+	Name   string `json:"name,omitempty"`
+	Aired  string `json:"aired,omitempty"` // air date like "2005-03-24", if known
+}
+
+// LookupShow returns s with its Year and ID set from the show p finds for
+// s.Name. If s.Year is set, only shows from that year match. Shows named
+// exactly s.Name are preferred over the provider's ordering.
+func LookupShow(ctx context.Context, p MetadataProvider, s Show) (Show, error) {
+	shows, err := p.SearchShows(ctx, s.Name)
+	if err != nil {
+		return Show{}, err
+	}
+	i, err := bestMatch(shows, s)
+	if err != nil {
+		return Show{}, err
+	}
+	s.Year, s.ID = shows[i].Year, shows[i].ID
+	return s, nil
+}
+
+// LookupMovie returns m with its Year and ID set from the movie p finds for
+// m.Name, as in [LookupShow].
+func LookupMovie(ctx context.Context, p MetadataProvider, m Movie) (Movie, error) {
+	movies, err := p.SearchMovies(ctx, m.Name)
+	if err != nil {
+		return Movie{}, err
+	}
+	shows := make([]Show, len(movies))
+	for i, mv := range movies {
+		shows[i] = mv.Show
+	}
+	i, err := bestMatch(shows, m.Show)
+	if err != nil {
+		return Movie{}, err
+	}
+	m.Year, m.ID = shows[i].Year, shows[i].ID
+	return m, nil
+}
+
+// bestMatch returns the index of the result in rs that best matches s.
+func bestMatch(rs []Show, s Show) (int, error) {
+	match := -1
+	for i, r := range rs {
+		if s.Year != "" && r.Year != s.Year {
+			continue
+		}
+		if normalize(r.Name) == normalize(s.Name) {
+			return i, nil
+		}
+		if match < 0 {
+			match = i
+		}
+	}
+	if match < 0 {
+		if s.Year != "" {
+			return 0, fmt.Errorf("no results for %q (%s)", s.Name, s.Year)
+		}
+		return 0, fmt.Errorf("no results for %q", s.Name)
+	}
+	return match, nil
+}
+
+// sortMetaEpisodes sorts es by season and episode number.
+func sortMetaEpisodes(es []Episode) {
+	slices.SortFunc(es, func(a, b Episode) int {
+		return cmp.Or(cmp.Compare(a.Season, b.Season), cmp.Compare(a.Number, b.Number))
+	})
+}
+
+// An APIError is an unsuccessful response from a metadata or Jellyfin API.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return http.StatusText(e.StatusCode)
+	}
+	return fmt.Sprintf("%s: %s", http.StatusText(e.StatusCode), e.Message)
+}
+
+// errUnauthorized reports whether err is an [APIError] for an unauthorized
+// request.
+func errUnauthorized(err error) bool {
+	var e *APIError
+	return errors.As(err, &e) && e.StatusCode == http.StatusUnauthorized
+}
+
+// doJSON sends req with c and decodes the JSON response into v.
+func doJSON(c *http.Client, req *http.Request, v any) error {
+	if c == nil {
+		c = http.DefaultClient
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		var msg struct {
+			Message       string `json:"message"`
+			StatusMessage string `json:"status_message"`
+		}
+		_ = json.Unmarshal(b, &msg)
+		if msg.Message == "" {
+			msg.Message = msg.StatusMessage
+		}
+		return &APIError{StatusCode: resp.StatusCode, Message: msg.Message}
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,147 @@
+// Copyright 2024 Matthew P. Dargan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package media
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// progressInterval is the number of bytes copied between progress reports.
+const progressInterval = 256 << 20
+
+// move renames src to dst. If src and dst are on different filesystems, move
+// copies src to dst, verifies the copy, and removes src once the copy is
+// durable.
+func move(ctx context.Context, src, dst string, progress func(written, size int64)) error {
+	err := os.Rename(src, dst)
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+	if err = copyFile(ctx, src, dst, progress); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+// copyFile copies src to dst through a temporary file in dst's directory.
+// The copy is synced to disk and its size and SHA-256 checksum are compared
+// with src before it is renamed to dst. If ctx is canceled, the copy stops
+// and the temporary file is removed.
+func copyFile(ctx context.Context, src, dst string, progress func(written, size int64)) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+	h := sha256.New()
+	w := io.MultiWriter(tmp, h, &progressWriter{size: info.Size(), next: progressInterval, progress: progress})
+	if _, err = io.CopyBuffer(w, ctxReader{ctx, in}, make([]byte, 1<<20)); err != nil {
+		return err
+	}
+	if progress != nil && info.Size() >= progressInterval {
+		progress(info.Size(), info.Size())
+	}
+	if err = tmp.Chmod(info.Mode().Perm()); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = verify(tmp, info.Size(), h.Sum(nil)); err != nil {
+		return fmt.Errorf("copy %q: %w", src, err)
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Chtimes(tmp.Name(), info.ModTime(), info.ModTime()); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp.Name(), dst); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(dst))
+}
+
+// verify reports whether f has the given size and SHA-256 checksum.
+func verify(f *os.File, size int64, sum []byte) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() != size {
+		return fmt.Errorf("size mismatch: got %d bytes, want %d", info.Size(), size)
+	}
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	h := sha256.New()
+	if _, err = io.CopyBuffer(h, f, make([]byte, 1<<20)); err != nil {
+		return err
+	}
+	if !bytes.Equal(h.Sum(nil), sum) {
+		return errors.New("checksum mismatch")
+	}
+	return nil
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// A progressWriter reports the number of bytes written to it every
+// progressInterval bytes.
+type progressWriter struct {
+	written, size, next int64
+	progress            func(written, size int64)
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.written += int64(len(p))
+	if w.progress != nil && w.written >= w.next && w.written < w.size {
+		w.progress(w.written, w.size)
+		w.next = w.written + progressInterval
+	}
+	return len(p), nil
+}
+
+// A ctxReader reads from r until ctx is canceled.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
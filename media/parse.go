@@ -0,0 +1,198 @@
+// Copyright 2024 Matthew P. Dargan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package media
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	idSuffixRe    = regexp.MustCompile(`\[(imdbid|tmdbid|tvdbid)-([^\[\]\s]+)\]$`)
+	yearSuffixRe  = regexp.MustCompile(`\((\d{4})\)$`)
+	seasonDirRe   = regexp.MustCompile(`^Season (\d+)$`)
+	episodeFileRe = regexp.MustCompile(`^(?:(.*?)[ ._-])?S(\d{2,})E(\d{2,})(?:-E(\d{2,}))?(?:-part(\d+))?(?: - (.+?))?(?: \((\d+)\))?(` + suffixExpr() + `)?$`)
+)
+
+// suffixExpr returns the regular expression matching the suffixes of episode
+// files: a video extension, like ".mkv", or a sidecar or artwork extension
+// after any language and flag tags, like ".en.forced.srt".
+func suffixExpr() string {
+	alt := func(exts []string) string {
+		qs := make([]string, len(exts))
+		for i, ext := range exts {
+			qs[i] = regexp.QuoteMeta(ext[1:])
+		}
+		return strings.Join(qs, "|")
+	}
+	tag := `[a-z]{2,3}(?:-[a-z]{2,4})?|cc|default|forced|hi|sdh`
+	return `(?i:\.(?:` + alt(videoExts) + `)|(?:\.(?:` + tag + `))*\.(?:` + alt(slices.Concat(sidecarExts, artExts)) + `))`
+}
+
+// ParseShowDir parses show directory names like
+// "Shameless (US) (2011) [tvdbid-161511]", the inverse of [MkShow]. It reads
+// the year and provider ID tags from the end of name so that the show name
+// may hold parentheses. The year and tags are optional, and the TVDB ID, if
+// any, is the show's ID.
+func ParseShowDir(name string) (Show, error) {
+	s, err := parseLabeled(name)
+	if err != nil {
+		return Show{}, fmt.Errorf("invalid show directory %q: %w", name, err)
+	}
+	s.ID = s.TVDBID
+	return s, nil
+}
+
+// ParseSeasonDir returns the season number of season directory names like
+// "Season 01", or 0 for [SpecialsDir].
+func ParseSeasonDir(name string) (int, error) {
+	if name == SpecialsDir {
+		return 0, nil
+	}
+	m := seasonDirRe.FindStringSubmatch(name)
+	if m == nil {
+		return 0, fmt.Errorf("invalid season directory %q", name)
+	}
+	return strconv.Atoi(m[1])
+}
+
+// An EpisodeFile is an episode file labeled like [MkSeason] labels episodes.
+type EpisodeFile struct {
+	Show    string // show name; empty if the file name has none
+	Season  int
+	Episode int
+	Last    int    // last episode in the file; equal to Episode for single episodes
+	Part    int    // part of an episode split across files, like 1 in "Lost S01E01-part1.mkv"; 0 if none
+	Title   string // episode title, like "Pilot" in "Lost S01E01 - Pilot.mkv"
+	Dup     int    // number the Suffix conflict policy gave the file, like 1 in "Lost S01E01 (1).mkv"; 0 if none
+	Suffix  string // extension, along with any sidecar suffix like ".en.srt"
+}
+
+// ParseEpisodeFile parses episode file names like "Series Name S01E01.mkv",
+// "Series Name S01E01-E02.mkv", "Series Name S01E01-part1.mkv", or
+// "Series Name S01E01.en.srt", the inverse of [MkSeason]. Names may hold an
+// episode title after the episode number, like
+// "Series Name S01E01 - Pilot.mkv", as Jellyfin allows, and the copy number
+// the [Suffix] conflict policy adds, like "Series Name S01E01 (1).mkv".
+func ParseEpisodeFile(name string) (EpisodeFile, error) {
+	e, err := parseEpisode(name)
+	if err != nil {
+		return EpisodeFile{}, fmt.Errorf("invalid episode file %q: %w", name, err)
+	}
+	return e, nil
+}
+
+// parseEpisode parses episode file names as in [ParseEpisodeFile].
+func parseEpisode(name string) (EpisodeFile, error) {
+	m := episodeFileRe.FindStringSubmatch(name)
+	if m == nil {
+		return EpisodeFile{}, errors.New("missing SxxEyy")
+	}
+	e := EpisodeFile{Show: m[1], Title: m[6], Suffix: m[8]}
+	if m[5] != "" {
+		e.Part, _ = strconv.Atoi(m[5])
+	}
+	if m[7] != "" {
+		e.Dup, _ = strconv.Atoi(m[7])
+	}
+	e.Season, _ = strconv.Atoi(m[2])
+	e.Episode, _ = strconv.Atoi(m[3])
+	e.Last = e.Episode
+	if m[4] != "" {
+		e.Last, _ = strconv.Atoi(m[4])
+		if e.Last <= e.Episode {
+			return EpisodeFile{}, fmt.Errorf("invalid episode range %d-%d", e.Episode, e.Last)
+		}
+	}
+	return e, nil
+}
+
+// ParseMovieFile parses movie file names like
+// "Film (2018) [imdbid-tt0112573] [tmdbid-197].mkv", the inverse of
+// [AddMovie]. The year and tags are optional, and the TMDB ID, if any, is the
+// movie's ID. The movie's File is name.
+func ParseMovieFile(name string) (Movie, error) {
+	s, err := parseLabeled(strings.TrimSuffix(name, filepath.Ext(name)))
+	if err != nil {
+		return Movie{}, fmt.Errorf("invalid movie file %q: %w", name, err)
+	}
+	s.ID = s.TMDBID
+	return Movie{Show: s, File: name}, nil
+}
+
+// ParseIDs parses provider IDs given as a bare ID like "73244", which is
+// returned as the show's ID, or as comma-separated tags like
+// "imdbid-tt0112573,tmdbid-197", spelled as in names like
+// "Film (2018) [imdbid-tt0112573] [tmdbid-197]". Only the ID fields of the
+// show are set.
+func ParseIDs(ids string) (Show, error) {
+	var s Show
+	if !strings.Contains(ids, "-") {
+		s.ID = ids
+		return s, nil
+	}
+	for _, tag := range strings.Split(ids, ",") {
+		name, id, _ := strings.Cut(tag, "-")
+		if err := s.setID(name, id); err != nil {
+			return Show{}, err
+		}
+	}
+	return s, nil
+}
+
+// setID sets the provider ID of s labeled tag to id. It reports unknown tags,
+// IDs the provider does not accept, and tags s already has an ID for.
+func (s *Show) setID(tag, id string) error {
+	fields := map[string]*string{"imdbid": &s.IMDBID, "tmdbid": &s.TMDBID, "tvdbid": &s.TVDBID}
+	for _, p := range providerIDs {
+		if p.tag != tag {
+			continue
+		}
+		if *fields[tag] != "" {
+			return fmt.Errorf("duplicate %s tag", tag)
+		}
+		if !p.re.MatchString(id) {
+			return fmt.Errorf("invalid %s %q", p.field, id)
+		}
+		*fields[tag] = id
+		return nil
+	}
+	return fmt.Errorf("unknown provider ID tag %q", tag)
+}
+
+// parseLabeled parses names labeled like [MkShow] and [AddMovie] label
+// shows and movies, like "Film (2018) [imdbid-tt0112573] [tmdbid-197]".
+func parseLabeled(name string) (Show, error) {
+	var s Show
+	rest := strings.TrimSpace(name)
+	for {
+		if m := idSuffixRe.FindStringSubmatchIndex(rest); m != nil {
+			if err := s.setID(rest[m[2]:m[3]], rest[m[4]:m[5]]); err != nil {
+				return Show{}, err
+			}
+			rest = strings.TrimRight(rest[:m[0]], " ")
+			continue
+		}
+		if m := yearSuffixRe.FindStringSubmatchIndex(rest); m != nil && s.Year == "" {
+			if m[0] > 0 && rest[m[0]-1] != ' ' {
+				return Show{}, errors.New("missing space before year")
+			}
+			s.Year = rest[m[2]:m[3]]
+			rest = strings.TrimRight(rest[:m[0]], " ")
+			continue
+		}
+		break
+	}
+	if rest == "" {
+		return Show{}, errors.New("missing name")
+	}
+	s.Name = rest
+	return s, nil
+}
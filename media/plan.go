@@ -0,0 +1,496 @@
+// Copyright 2024 Matthew P. Dargan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package media
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// An OpKind is the kind of filesystem operation an [Op] performs.
+type OpKind int
+
+const (
+	Rename  OpKind = iota // rename Src to Dst
+	Link                  // hard link Dst to Src
+	Symlink               // symbolic link Dst to Src
+	Reflink               // clone Src to Dst, sharing extents
+	Copy                  // copy Src to Dst
+	Mkdir                 // create directory Dst
+	Remove                // remove file or empty directory Dst
+)
+
+var opKinds = []string{
+	Rename:  "rename",
+	Link:    "link",
+	Symlink: "symlink",
+	Reflink: "reflink",
+	Copy:    "copy",
+	Mkdir:   "mkdir",
+	Remove:  "remove",
+}
+
+func (k OpKind) String() string {
+	if k >= 0 && int(k) < len(opKinds) {
+		return opKinds[k]
+	}
+	return fmt.Sprintf("OpKind(%d)", int(k))
+}
+
+// MarshalText implements [encoding.TextMarshaler].
+func (k OpKind) MarshalText() ([]byte, error) {
+	if k < 0 || int(k) >= len(opKinds) {
+		return nil, fmt.Errorf("unknown operation %d", int(k))
+	}
+	return []byte(opKinds[k]), nil
+}
+
+// IsPlacement reports whether k places a file in a library: [Rename], [Link],
+// [Symlink], [Reflink], or [Copy].
+func (k OpKind) IsPlacement() bool {
+	return k >= Rename && k <= Copy
+}
+
+// UnmarshalText implements [encoding.TextUnmarshaler].
+func (k *OpKind) UnmarshalText(b []byte) error {
+	i := slices.Index(opKinds, string(b))
+	if i < 0 {
+		return fmt.Errorf("unknown operation %q", b)
+	}
+	*k = OpKind(i)
+	return nil
+}
+
+// An Op is a single filesystem operation.
+type Op struct {
+	Kind    OpKind `json:"kind"`
+	Src     string `json:"src,omitempty"` // empty for Mkdir and Remove
+	Dst     string `json:"dst"`
+	Replace bool   `json:"replace,omitempty"` // placement replaces an existing Dst
+	Backup  string `json:"backup,omitempty"`  // where a replacing placement or a removal kept the file it displaced
+	Dir     bool   `json:"dir,omitempty"`     // Remove removed a directory
+}
+
+func (o Op) String() string {
+	if o.Src == "" {
+		return fmt.Sprintf("%v %q", o.Kind, o.Dst)
+	}
+	if o.Replace {
+		return fmt.Sprintf("%v %q %q (replace)", o.Kind, o.Src, o.Dst)
+	}
+	return fmt.Sprintf("%v %q %q", o.Kind, o.Src, o.Dst)
+}
+
+func (o Op) apply(ctx context.Context, progress func(Op, int64, int64)) error {
+	if o.Kind.IsPlacement() && !o.Replace {
+		if _, err := os.Lstat(o.Dst); err == nil {
+			return &ConflictError{Src: o.Src, Dst: o.Dst}
+		}
+	}
+	if o.Replace && (o.Kind == Link || o.Kind == Symlink || o.Kind == Reflink) {
+		// Place the file beside Dst and rename it over Dst, so that Dst
+		// is replaced atomically.
+		tmp := filepath.Join(filepath.Dir(o.Dst), "."+filepath.Base(o.Dst)+".replace")
+		r := o
+		r.Dst, r.Replace = tmp, false
+		if err := r.apply(ctx, progress); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, o.Dst); err != nil {
+			os.Remove(tmp)
+			return err
+		}
+		return nil
+	}
+	switch o.Kind {
+	case Mkdir:
+		return os.Mkdir(o.Dst, 0o755)
+	case Rename:
+		var f func(int64, int64)
+		if progress != nil {
+			f = func(written, size int64) { progress(o, written, size) }
+		}
+		return move(ctx, o.Src, o.Dst, f)
+	case Link:
+		return os.Link(o.Src, o.Dst)
+	case Symlink:
+		src, err := filepath.Abs(o.Src)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(src, o.Dst)
+	case Reflink:
+		return reflink(o.Src, o.Dst)
+	case Copy:
+		var f func(int64, int64)
+		if progress != nil {
+			f = func(written, size int64) { progress(o, written, size) }
+		}
+		return copyFile(ctx, o.Src, o.Dst, f)
+	case Remove:
+		return os.Remove(o.Dst)
+	}
+	return fmt.Errorf("unknown operation %v", o.Kind)
+}
+
+// inverse returns the operations that reverse o. Reversing a replacing
+// placement or a file removal restores the file it displaced from its backup.
+func (o Op) inverse() ([]Op, error) {
+	var inv Op
+	switch {
+	case o.Kind == Mkdir:
+		inv = Op{Kind: Remove, Dst: o.Dst}
+	case o.Kind == Rename:
+		inv = Op{Kind: Rename, Src: o.Dst, Dst: o.Src}
+	case o.Kind.IsPlacement():
+		inv = Op{Kind: Remove, Dst: o.Dst}
+	case o.Kind == Remove && o.Dir:
+		return []Op{{Kind: Mkdir, Dst: o.Dst}}, nil
+	case o.Kind == Remove && o.Backup != "":
+		return []Op{{Kind: Rename, Src: o.Backup, Dst: o.Dst}}, nil
+	default:
+		return nil, fmt.Errorf("cannot reverse %v", o)
+	}
+	if o.Backup != "" {
+		return []Op{inv, {Kind: Rename, Src: o.Backup, Dst: o.Dst}}, nil
+	}
+	return []Op{inv}, nil
+}
+
+// A Plan is a sequence of filesystem operations that categorize media.
+// Plans are built without modifying the filesystem, so callers can inspect
+// them before calling [Plan.Apply].
+type Plan struct {
+	Ops []Op
+}
+
+// DefaultConcurrency is the maximum number of placements [Plan.Apply] runs at
+// once if [Options] does not set one.
+const DefaultConcurrency = 4
+
+// Options control how plans are built and applied.
+type Options struct {
+	Mode        OpKind         // placement operation; Rename by default
+	Conflict    ConflictPolicy // what to do if a file would be placed at an existing path; Fail by default
+	Names       NameProfile    // file name rules of the library; POSIX by default
+	Concurrency int            // maximum placements run at once; DefaultConcurrency if not positive
+
+	// Progress, if non-nil, is called periodically while a file is copied,
+	// including renames across filesystems.
+	Progress func(o Op, written, size int64)
+}
+
+// A Result lists the changes applying a plan made.
+type Result struct {
+	Created []string // directories created, in order
+	Placed  []Op     // files placed, like renamed episodes, in the order they finished
+	Removed []string // files and directories removed, in order
+}
+
+// result returns the result of performing ops.
+func result(ops []Op) Result {
+	var r Result
+	for _, o := range ops {
+		switch {
+		case o.Kind == Mkdir:
+			r.Created = append(r.Created, o.Dst)
+		case o.Kind == Remove:
+			r.Removed = append(r.Removed, o.Dst)
+		case o.Kind.IsPlacement():
+			r.Placed = append(r.Placed, o)
+		}
+	}
+	return r
+}
+
+// String returns the operations in p, one per line.
+func (p Plan) String() string {
+	var b strings.Builder
+	for _, o := range p.Ops {
+		fmt.Fprintln(&b, o)
+	}
+	return b.String()
+}
+
+// Apply performs the operations in p as a single transaction. Every
+// operation is validated before any runs: sources must exist, destinations
+// must not exist unless they are replaced or vacated by an earlier operation,
+// and parent directories must exist or be created by p. Directories are then
+// created in order, placements run concurrently, up to opts.Concurrency at
+// once, and removals, along with placements into vacated paths, run in order
+// once placements finish. If an operation fails, the operations already
+// performed are reversed, removing the directories p created and restoring
+// replaced and removed files. Renames across filesystems fall back to copying
+// and removing the source. Only the Concurrency and Progress fields of opts
+// are used.
+//
+// A file replaced by a placement is kept beside it as a hidden backup, like
+// ".Series Name S01E01.mkv.epify-backup-0", whose path the result's placed
+// operation records, so that reversing the placement restores the file.
+//
+// If ctx is canceled, Apply stops starting operations, abandons copies in
+// progress, and returns ctx.Err(). The operations that completed are kept
+// rather than reversed, so that an interrupted batch need not be copied back;
+// the result lists them. If p fails, the result lists the operations that
+// could not be reversed. Unless p succeeds, the files it removed are kept as
+// backups like replaced files, so that the operations the result lists can be
+// reversed.
+func (p Plan) Apply(ctx context.Context, opts Options) (Result, error) {
+	ops, err := p.apply(ctx, opts)
+	return result(ops), err
+}
+
+// validate reports the operations in p that cannot be performed.
+func (p Plan) validate() error {
+	var errs []error
+	created := make(map[string]bool)
+	claimed := make(map[string]bool)
+	vacated := make(map[string]bool)
+	present := func(path string) (bool, error) {
+		_, err := os.Lstat(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	isDir := func(path string) bool {
+		if created[path] {
+			return true
+		}
+		info, err := os.Stat(path)
+		return err == nil && info.IsDir()
+	}
+	for _, o := range p.Ops {
+		dst := filepath.Clean(o.Dst)
+		ok, err := present(dst)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		switch {
+		case o.Kind == Remove:
+			if !ok {
+				errs = append(errs, fmt.Errorf("cannot remove %q: %w", o.Dst, fs.ErrNotExist))
+			}
+			vacated[dst] = true
+			continue
+		case o.Kind.IsPlacement():
+			if srcOK, err := present(o.Src); err != nil || !srcOK {
+				errs = append(errs, fmt.Errorf("cannot place %q: %w", o.Src, cmp.Or(err, fs.ErrNotExist)))
+			}
+			if (ok && !o.Replace && !vacated[dst]) || claimed[dst] || created[dst] {
+				errs = append(errs, &ConflictError{Src: o.Src, Dst: o.Dst})
+			}
+			if o.Kind == Rename {
+				vacated[filepath.Clean(o.Src)] = true
+			}
+		case o.Kind == Mkdir:
+			if ok || created[dst] || claimed[dst] {
+				errs = append(errs, fmt.Errorf("cannot create %q: %w", o.Dst, fs.ErrExist))
+			}
+			created[dst] = true
+		default:
+			errs = append(errs, fmt.Errorf("unknown operation %v", o.Kind))
+			continue
+		}
+		if parent := filepath.Dir(dst); !isDir(parent) {
+			errs = append(errs, fmt.Errorf("cannot create %q: %q is not a directory", o.Dst, parent))
+		}
+		claimed[dst] = true
+	}
+	return errors.Join(errs...)
+}
+
+// A backup is a file moved aside by a replacing placement or a removal, so
+// that it can be restored if the plan fails or is reversed.
+type backup struct {
+	path, orig string
+}
+
+// apply performs the operations in p as in [Plan.Apply] and returns the
+// operations that remain performed: all of them if p succeeds, those that
+// completed if ctx is canceled, and those that could not be reversed if it
+// fails.
+func (p Plan) apply(ctx context.Context, opts Options) ([]Op, error) {
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	var (
+		mu      sync.Mutex
+		applied []Op
+		removed = make(map[string]bool)
+	)
+	for _, o := range p.Ops {
+		if o.Kind == Remove {
+			removed[filepath.Clean(o.Dst)] = true
+		}
+	}
+	// backupDir returns the directory to keep the backup of the file at path
+	// in: the file's directory, or its nearest ancestor p does not remove.
+	backupDir := func(path string) string {
+		dir := filepath.Dir(path)
+		for removed[dir] && dir != filepath.Dir(dir) {
+			dir = filepath.Dir(dir)
+		}
+		return dir
+	}
+	run := func(ctx context.Context, i int) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		o := p.Ops[i]
+		var (
+			b     backup
+			moved bool
+		)
+		if o.Replace && exists(o.Dst) || o.Kind == Remove && !isDirPath(o.Dst) {
+			var err error
+			if b, err = moveAside(o.Dst, backupDir(o.Dst)); err != nil {
+				return err
+			}
+			moved = true
+		}
+		if !moved || o.Kind != Remove {
+			if err := o.apply(ctx, opts.Progress); err != nil {
+				if moved {
+					err = errors.Join(err, os.Rename(b.path, b.orig))
+				}
+				return err
+			}
+		}
+		mu.Lock()
+		if moved {
+			o.Backup = b.path
+		}
+		o.Dir = o.Kind == Remove && !moved
+		applied = append(applied, o)
+		mu.Unlock()
+		return nil
+	}
+	// Placements into paths vacated by earlier operations wait for them.
+	vacated := make(map[string]bool)
+	deferred := make(map[int]bool)
+	for i, o := range p.Ops {
+		deferred[i] = o.Kind.IsPlacement() && vacated[filepath.Clean(o.Dst)]
+		switch o.Kind {
+		case Remove:
+			vacated[filepath.Clean(o.Dst)] = true
+		case Rename:
+			vacated[filepath.Clean(o.Src)] = true
+		}
+	}
+	err := func() error {
+		for i, o := range p.Ops {
+			if o.Kind == Mkdir {
+				if err := run(ctx, i); err != nil {
+					return err
+				}
+			}
+		}
+		// A failed placement cancels the placements not yet started.
+		g, gctx := errgroup.WithContext(ctx)
+		g.SetLimit(cmp.Or(max(opts.Concurrency, 0), DefaultConcurrency))
+		for i, o := range p.Ops {
+			if o.Kind.IsPlacement() && !deferred[i] {
+				g.Go(func() error { return run(gctx, i) })
+			}
+		}
+		if err := g.Wait(); err != nil {
+			return err
+		}
+		for i, o := range p.Ops {
+			if o.Kind == Remove || deferred[i] {
+				if err := run(ctx, i); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}()
+	if err != nil && ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+		return applied, err
+	}
+	if err != nil {
+		remaining, rerr := rollback(applied)
+		if rerr != nil {
+			err = errors.Join(err, fmt.Errorf("rollback: %w", rerr))
+		}
+		return remaining, err
+	}
+	for i, o := range applied {
+		if o.Kind == Remove && o.Backup != "" {
+			os.Remove(o.Backup)
+			applied[i].Backup = ""
+		}
+	}
+	return applied, nil
+}
+
+// rollback reverses the operations in applied, in reverse order, restoring
+// the files they displaced from their backups. It returns the operations it
+// could not reverse.
+func rollback(applied []Op) ([]Op, error) {
+	var (
+		remaining []Op
+		errs      []error
+	)
+	for i := len(applied) - 1; i >= 0; i-- {
+		o := applied[i]
+		inv, err := o.inverse()
+		for _, r := range inv {
+			if err == nil {
+				err = r.apply(context.Background(), nil)
+			}
+		}
+		if err != nil {
+			remaining = append(remaining, o)
+			errs = append(errs, err)
+		}
+	}
+	slices.Reverse(remaining)
+	return remaining, errors.Join(errs...)
+}
+
+// moveAside renames the file at path to an unused name in dir.
+func moveAside(path, dir string) (backup, error) {
+	base := filepath.Base(path)
+	for n := 0; ; n++ {
+		b := backup{path: filepath.Join(dir, fmt.Sprintf(".%s.epify-backup-%d", base, n)), orig: path}
+		if _, err := os.Lstat(b.path); errors.Is(err, fs.ErrNotExist) {
+			return b, os.Rename(path, b.path)
+		}
+	}
+}
+
+// isBackup reports whether name is the name of a file moved aside by
+// [Plan.Apply], like ".Series Name S01E01.mkv.epify-backup-0".
+func isBackup(name string) bool {
+	return strings.HasPrefix(name, ".") && backupRe.MatchString(name)
+}
+
+var backupRe = regexp.MustCompile(`\.epify-backup-\d+$`)
+
+// exists reports whether a file exists at path.
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// isDirPath reports whether path is a directory.
+func isDirPath(path string) bool {
+	info, err := os.Lstat(path)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,173 @@
+// Copyright 2024 Matthew P. Dargan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package media
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// videoHeight returns the height in pixels of the tallest video track in the
+// Matroska or MP4 file at path, or 0 if it cannot be read.
+func videoHeight(path string) int {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return 0
+	}
+	var h int
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mkv", ".webm":
+		h, err = mkvHeight(f, 0, info.Size(), 0)
+	case ".mp4", ".m4v", ".mov":
+		h, err = mp4Height(f, 0, info.Size())
+	}
+	if err != nil {
+		return 0
+	}
+	return h
+}
+
+// Matroska element IDs, with their length markers, on the path to the pixel
+// height of video tracks.
+const (
+	mkvSegment     = 0x18538067
+	mkvTracks      = 0x1654AE6B
+	mkvCluster     = 0x1F43B675
+	mkvTrackEntry  = 0xAE
+	mkvVideo       = 0xE0
+	mkvPixelHeight = 0xBA
+)
+
+// mkvHeight returns the tallest pixel height of the Matroska elements in r
+// from start to end, at the given nesting depth. It stops at the first
+// cluster, since tracks precede the media they describe.
+func mkvHeight(r io.ReaderAt, start, end int64, depth int) (int, error) {
+	if depth > 4 {
+		return 0, errors.New("matroska elements nested too deeply")
+	}
+	var h int
+	for off := start; off < end; {
+		id, n, err := readVint(r, off, true)
+		if err != nil {
+			return 0, err
+		}
+		size, m, err := readVint(r, off+int64(n), false)
+		if err != nil {
+			return 0, err
+		}
+		data := off + int64(n+m)
+		next := data + size
+		if size < 0 || next > end {
+			// Elements of unknown size extend to the end of their parent.
+			next = end
+		}
+		switch id {
+		case mkvCluster:
+			return h, nil
+		case mkvSegment, mkvTracks, mkvTrackEntry, mkvVideo:
+			sh, err := mkvHeight(r, data, next, depth+1)
+			if err != nil {
+				return 0, err
+			}
+			h = max(h, sh)
+		case mkvPixelHeight:
+			if size < 1 || size > 8 {
+				return 0, errors.New("invalid matroska pixel height")
+			}
+			b := make([]byte, 8)
+			if _, err = r.ReadAt(b[8-size:], data); err != nil {
+				return 0, err
+			}
+			h = max(h, int(binary.BigEndian.Uint64(b)))
+		}
+		off = next
+	}
+	return h, nil
+}
+
+// readVint reads the EBML variable-length integer at off in r and returns it
+// and its length. IDs keep their length marker. A size with all its bits set
+// is unknown and returned as -1.
+func readVint(r io.ReaderAt, off int64, id bool) (int64, int, error) {
+	b := make([]byte, 8)
+	if _, err := r.ReadAt(b[:1], off); err != nil {
+		return 0, 0, err
+	}
+	n := 1
+	for n <= 8 && b[0]&(0x80>>(n-1)) == 0 {
+		n++
+	}
+	if n > 8 || id && n > 4 {
+		return 0, 0, errors.New("invalid matroska integer")
+	}
+	if _, err := r.ReadAt(b[1:n], off+1); err != nil {
+		return 0, 0, err
+	}
+	if !id {
+		b[0] &^= 0x80 >> (n - 1)
+	}
+	var v int64
+	for _, c := range b[:n] {
+		v = v<<8 | int64(c)
+	}
+	if !id && v == 1<<(7*n)-1 {
+		return -1, n, nil
+	}
+	return v, n, nil
+}
+
+// mp4Height returns the tallest track height of the MP4 boxes in r from start
+// to end.
+func mp4Height(r io.ReaderAt, start, end int64) (int, error) {
+	var h int
+	for off := start; off+8 <= end; {
+		var hdr [16]byte
+		if _, err := r.ReadAt(hdr[:8], off); err != nil {
+			return 0, err
+		}
+		size, data := int64(binary.BigEndian.Uint32(hdr[:4])), off+8
+		switch size {
+		case 0:
+			size = end - off
+		case 1:
+			if _, err := r.ReadAt(hdr[8:], off+8); err != nil {
+				return 0, err
+			}
+			size, data = int64(binary.BigEndian.Uint64(hdr[8:])), off+16
+		}
+		if size < data-off || off+size > end {
+			return 0, errors.New("invalid mp4 box size")
+		}
+		switch string(hdr[4:8]) {
+		case "moov", "trak":
+			sh, err := mp4Height(r, data, off+size)
+			if err != nil {
+				return 0, err
+			}
+			h = max(h, sh)
+		case "tkhd":
+			// The track height, a 16.16 fixed-point number, ends the box.
+			var b [4]byte
+			if off+size-4 < data {
+				return 0, errors.New("invalid mp4 track header")
+			}
+			if _, err := r.ReadAt(b[:], off+size-4); err != nil {
+				return 0, err
+			}
+			h = max(h, int(binary.BigEndian.Uint32(b[:])>>16))
+		}
+		off += size
+	}
+	return h, nil
+}
@@ -0,0 +1,41 @@
+// Copyright 2024 Matthew P. Dargan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package media
+
+import (
+	"os"
+	"syscall"
+)
+
+// ficlone is the FICLONE ioctl request from linux/fs.h.
+const ficlone = 0x40049409
+
+// reflink clones src to dst. The clone shares extents with src until either
+// file is modified.
+func reflink(src, dst string) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			out.Close()
+			os.Remove(dst)
+		}
+	}()
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, out.Fd(), ficlone, in.Fd()); errno != 0 {
+		return &os.LinkError{Op: "reflink", Old: src, New: dst, Err: errno}
+	}
+	return out.Close()
+}
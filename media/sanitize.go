@@ -0,0 +1,137 @@
+// Copyright 2024 Matthew P. Dargan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package media
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// A NameProfile specifies the rules for the file names a library's
+// filesystem accepts. Show and movie names are made to follow them before
+// they are put in paths, so a title like "Face/Off" does not create a nested
+// directory.
+type NameProfile int
+
+const (
+	POSIX   NameProfile = iota // "/" is illegal and names are limited to 255 bytes
+	Windows                    // `\/:*?"<>|` and control characters are illegal, names cannot end in dots or spaces or be reserved device names, and names are limited to 255 UTF-16 code units
+	SMB                        // Windows rules, with names limited to 255 bytes for shares backed by POSIX filesystems
+)
+
+var nameProfiles = []string{
+	POSIX:   "posix",
+	Windows: "windows",
+	SMB:     "smb",
+}
+
+func (p NameProfile) String() string {
+	if p >= 0 && int(p) < len(nameProfiles) {
+		return nameProfiles[p]
+	}
+	return fmt.Sprintf("NameProfile(%d)", int(p))
+}
+
+// MarshalText implements [encoding.TextMarshaler].
+func (p NameProfile) MarshalText() ([]byte, error) {
+	if p < 0 || int(p) >= len(nameProfiles) {
+		return nil, fmt.Errorf("unknown name profile %d", int(p))
+	}
+	return []byte(nameProfiles[p]), nil
+}
+
+// UnmarshalText implements [encoding.TextUnmarshaler].
+func (p *NameProfile) UnmarshalText(b []byte) error {
+	i := slices.Index(nameProfiles, string(b))
+	if i < 0 {
+		return fmt.Errorf("unknown name profile %q", b)
+	}
+	*p = NameProfile(i)
+	return nil
+}
+
+// maxName is the length limit of a file name, in bytes or UTF-16 code units.
+const maxName = 255
+
+// reservedNames are the device names Windows reserves, with or without an
+// extension.
+var reservedNames = []string{
+	"AUX", "CON", "NUL", "PRN",
+	"COM0", "COM1", "COM2", "COM3", "COM4", "COM5", "COM6", "COM7", "COM8", "COM9",
+	"LPT0", "LPT1", "LPT2", "LPT3", "LPT4", "LPT5", "LPT6", "LPT7", "LPT8", "LPT9",
+}
+
+var (
+	posixReplacer = strings.NewReplacer("/", "-", "\x00", "")
+	// windowsReplacer keeps names readable: "Mission: Impossible" becomes
+	// "Mission - Impossible" and "Face/Off" becomes "Face-Off".
+	windowsReplacer = strings.NewReplacer(
+		": ", " - ", ":", "-", "/", "-", `\`, "-", `"`, "'",
+		"*", "", "?", "", "<", "", ">", "", "|", "",
+	)
+)
+
+// Sanitize returns name, a single path element, with the characters p makes
+// illegal replaced or removed and truncated to the length limit of p.
+// Surrounding spaces are removed, along with trailing dots if p does not
+// allow them.
+func (p NameProfile) Sanitize(name string) string {
+	return p.fit(name, "")
+}
+
+// fit returns name sanitized as in [NameProfile.Sanitize] and truncated so
+// that name followed by suffix, which must already follow p, fits the length
+// limit of p.
+func (p NameProfile) fit(name, suffix string) string {
+	if p == Windows || p == SMB {
+		name = strings.Map(func(r rune) rune {
+			if r < ' ' || r == 0x7f {
+				return -1
+			}
+			return r
+		}, windowsReplacer.Replace(name))
+	} else {
+		name = posixReplacer.Replace(name)
+	}
+	name = p.trim(name, suffix == "")
+	for n := p.length(suffix); name != "" && p.length(name)+n > maxName; {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = p.trim(name[:len(name)-size], suffix == "")
+	}
+	if p == Windows || p == SMB {
+		stem, _, _ := strings.Cut(name+suffix, ".")
+		if len(stem) <= len(name) && slices.Contains(reservedNames, strings.ToUpper(strings.TrimRight(stem, " "))) {
+			name = stem + "_" + name[len(stem):]
+		}
+	}
+	return name
+}
+
+// trim removes the spaces around name, and its trailing dots if name ends a
+// file name and p does not allow them.
+func (p NameProfile) trim(name string, end bool) string {
+	if end && (p == Windows || p == SMB) {
+		return strings.TrimSpace(strings.TrimRight(name, ". "))
+	}
+	return strings.TrimSpace(name)
+}
+
+// length returns the length of name under the limit of p.
+func (p NameProfile) length(name string) int {
+	if p == Windows {
+		n := 0
+		for _, r := range name {
+			if r >= 0x10000 {
+				n += 2
+			} else {
+				n++
+			}
+		}
+		return n
+	}
+	return len(name)
+}
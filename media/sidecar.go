@@ -0,0 +1,91 @@
+// Copyright 2024 Matthew P. Dargan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package media
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// sidecarExts are the extensions of subtitle, audio, and metadata files that
+// accompany a video file.
+var sidecarExts = []string{
+	".aac", ".ac3", ".ass", ".dts", ".eac3", ".flac", ".idx", ".mka",
+	".nfo", ".smi", ".srt", ".ssa", ".sub", ".sup", ".vtt",
+}
+
+// isSidecar reports whether the file at path is a sidecar file.
+func isSidecar(path string) bool {
+	return slices.Contains(sidecarExts, strings.ToLower(filepath.Ext(path)))
+}
+
+// collectSidecars separates sidecar files from episodes in paths. It attaches
+// each sidecar, along with sidecars found beside each episode, to the episode
+// whose name it extends, like "ep05.en.srt" for "ep05.mkv".
+func collectSidecars(paths []string) ([]string, map[string][]string, error) {
+	var eps, scs []string
+	for _, p := range paths {
+		if isSidecar(p) {
+			scs = append(scs, p)
+		} else {
+			eps = append(eps, p)
+		}
+	}
+	dirs := make(map[string]bool)
+	for _, e := range eps {
+		dir := filepath.Dir(e)
+		if dirs[dir] {
+			continue
+		}
+		dirs[dir] = true
+		ents, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, ent := range ents {
+			p := filepath.Join(dir, ent.Name())
+			if ent.Type().IsRegular() && isSidecar(p) && !slices.Contains(scs, p) {
+				scs = append(scs, p)
+			}
+		}
+	}
+	sidecars := make(map[string][]string)
+	for _, sc := range scs {
+		var ep string
+		for _, e := range eps {
+			if filepath.Dir(e) == filepath.Dir(sc) && strings.HasPrefix(filepath.Base(sc), stem(e)+".") &&
+				(ep == "" || len(stem(e)) > len(stem(ep))) {
+				ep = e
+			}
+		}
+		if ep != "" {
+			sidecars[ep] = append(sidecars[ep], sc)
+		} else if slices.Contains(paths, sc) {
+			return nil, nil, fmt.Errorf("no episode for %q", sc)
+		}
+	}
+	return eps, sidecars, nil
+}
+
+// stem returns the base name of path without its extension.
+func stem(path string) string {
+	base := filepath.Base(path)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// place returns the operations that place the episode at src and its sidecars
+// in dir under name, keeping the extensions and sidecar suffixes like
+// ".forced.en".
+func place(kind OpKind, src string, sidecars []string, dir, name string) []Op {
+	ops := []Op{{Kind: kind, Src: src, Dst: filepath.Join(dir, name+filepath.Ext(src))}}
+	for _, sc := range sidecars {
+		suffix := strings.TrimPrefix(filepath.Base(sc), stem(src))
+		ops = append(ops, Op{Kind: kind, Src: sc, Dst: filepath.Join(dir, name+suffix)})
+	}
+	return ops
+}
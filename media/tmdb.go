@@ -0,0 +1,116 @@
+// Copyright 2024 Matthew P. Dargan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package media
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// TMDBURL is the base URL of the TMDB v3 API.
+const TMDBURL = "https://api.themoviedb.org/3"
+
+// A TMDB is a [MetadataProvider] for the TMDB v3 API. IDs are TMDB IDs,
+// which [AddMovie] labels movies with.
+type TMDB struct {
+	BaseURL string       // defaults to TMDBURL
+	Key     string       // API key
+	Client  *http.Client // defaults to http.DefaultClient
+}
+
+// SearchShows implements [MetadataProvider].
+func (t *TMDB) SearchShows(ctx context.Context, name string) ([]Show, error) {
+	var resp struct {
+		Results []struct {
+			ID           int    `json:"id"`
+			Name         string `json:"name"`
+			FirstAirDate string `json:"first_air_date"`
+		} `json:"results"`
+	}
+	if err := t.get(ctx, "/search/tv", url.Values{"query": {name}}, &resp); err != nil {
+		return nil, err
+	}
+	shows := make([]Show, len(resp.Results))
+	for i, r := range resp.Results {
+		shows[i] = Show{Name: r.Name, Year: year(r.FirstAirDate), ID: strconv.Itoa(r.ID)}
+	}
+	return shows, nil
+}
+
+// SearchMovies implements [MetadataProvider].
+func (t *TMDB) SearchMovies(ctx context.Context, name string) ([]Movie, error) {
+	var resp struct {
+		Results []struct {
+			ID          int    `json:"id"`
+			Title       string `json:"title"`
+			ReleaseDate string `json:"release_date"`
+		} `json:"results"`
+	}
+	if err := t.get(ctx, "/search/movie", url.Values{"query": {name}}, &resp); err != nil {
+		return nil, err
+	}
+	movies := make([]Movie, len(resp.Results))
+	for i, r := range resp.Results {
+		movies[i] = Movie{Show: Show{Name: r.Title, Year: year(r.ReleaseDate), ID: strconv.Itoa(r.ID)}}
+	}
+	return movies, nil
+}
+
+// Episodes implements [MetadataProvider].
+func (t *TMDB) Episodes(ctx context.Context, id string) ([]Episode, error) {
+	var show struct {
+		Seasons []struct {
+			SeasonNumber int `json:"season_number"`
+		} `json:"seasons"`
+	}
+	if err := t.get(ctx, "/tv/"+url.PathEscape(id), url.Values{}, &show); err != nil {
+		return nil, err
+	}
+	var es []Episode
+	for _, s := range show.Seasons {
+		var season struct {
+			Episodes []struct {
+				SeasonNumber  int    `json:"season_number"`
+				EpisodeNumber int    `json:"episode_number"`
+				Name          string `json:"name"`
+				AirDate       string `json:"air_date"`
+			} `json:"episodes"`
+		}
+		path := fmt.Sprintf("/tv/%s/season/%d", url.PathEscape(id), s.SeasonNumber)
+		if err := t.get(ctx, path, url.Values{}, &season); err != nil {
+			return nil, err
+		}
+		for _, e := range season.Episodes {
+			es = append(es, Episode{Season: e.SeasonNumber, Number: e.EpisodeNumber, Name: e.Name, Aired: e.AirDate})
+		}
+	}
+	sortMetaEpisodes(es)
+	return es, nil
+}
+
+// get decodes the JSON response to a GET request for path with query q into v.
+func (t *TMDB) get(ctx context.Context, path string, q url.Values, v any) error {
+	base := t.BaseURL
+	if base == "" {
+		base = TMDBURL
+	}
+	q.Set("api_key", t.Key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+path+"?"+q.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	return doJSON(t.Client, req, v)
+}
+
+// year returns the year of a date like "2005-03-24".
+func year(date string) string {
+	if len(date) < 4 {
+		return ""
+	}
+	return date[:4]
+}
@@ -0,0 +1,157 @@
+// Copyright 2024 Matthew P. Dargan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package media
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// TVDBURL is the base URL of the TVDB v4 API.
+const TVDBURL = "https://api4.thetvdb.com/v4"
+
+// A TVDB is a [MetadataProvider] for the TVDB v4 API. IDs are TVDB IDs,
+// which [MkShow] labels show directories with.
+type TVDB struct {
+	BaseURL string       // defaults to TVDBURL
+	Key     string       // API key
+	PIN     string       // subscriber PIN, if the key requires one
+	Client  *http.Client // defaults to http.DefaultClient
+
+	mu    sync.Mutex
+	token string
+}
+
+// SearchShows implements [MetadataProvider].
+func (t *TVDB) SearchShows(ctx context.Context, name string) ([]Show, error) {
+	return t.search(ctx, name, "series")
+}
+
+// SearchMovies implements [MetadataProvider].
+func (t *TVDB) SearchMovies(ctx context.Context, name string) ([]Movie, error) {
+	shows, err := t.search(ctx, name, "movie")
+	if err != nil {
+		return nil, err
+	}
+	movies := make([]Movie, len(shows))
+	for i, s := range shows {
+		movies[i] = Movie{Show: s}
+	}
+	return movies, nil
+}
+
+// Episodes implements [MetadataProvider].
+func (t *TVDB) Episodes(ctx context.Context, id string) ([]Episode, error) {
+	var es []Episode
+	for page := 0; ; page++ {
+		var resp struct {
+			Data struct {
+				Episodes []struct {
+					SeasonNumber int    `json:"seasonNumber"`
+					Number       int    `json:"number"`
+					Name         string `json:"name"`
+					Aired        string `json:"aired"`
+				} `json:"episodes"`
+			} `json:"data"`
+			Links struct {
+				Next *string `json:"next"`
+			} `json:"links"`
+		}
+		path := fmt.Sprintf("/series/%s/episodes/default?page=%d", url.PathEscape(id), page)
+		if err := t.get(ctx, path, &resp); err != nil {
+			return nil, err
+		}
+		for _, e := range resp.Data.Episodes {
+			es = append(es, Episode{Season: e.SeasonNumber, Number: e.Number, Name: e.Name, Aired: e.Aired})
+		}
+		if resp.Links.Next == nil || *resp.Links.Next == "" || len(resp.Data.Episodes) == 0 {
+			break
+		}
+	}
+	sortMetaEpisodes(es)
+	return es, nil
+}
+
+func (t *TVDB) search(ctx context.Context, name, typ string) ([]Show, error) {
+	q := url.Values{"query": {name}, "type": {typ}}
+	var resp struct {
+		Data []struct {
+			TVDBID string `json:"tvdb_id"`
+			Name   string `json:"name"`
+			Year   string `json:"year"`
+		} `json:"data"`
+	}
+	if err := t.get(ctx, "/search?"+q.Encode(), &resp); err != nil {
+		return nil, err
+	}
+	shows := make([]Show, len(resp.Data))
+	for i, d := range resp.Data {
+		shows[i] = Show{Name: d.Name, Year: d.Year, ID: d.TVDBID}
+	}
+	return shows, nil
+}
+
+// get decodes the JSON response to a GET request for path into v, logging
+// in first if t has no token or its token expired.
+func (t *TVDB) get(ctx context.Context, path string, v any) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for retry := false; ; retry = true {
+		if t.token == "" {
+			if err := t.login(ctx); err != nil {
+				return err
+			}
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.url(path), nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+t.token)
+		err = doJSON(t.Client, req, v)
+		if errUnauthorized(err) && !retry {
+			t.token = ""
+			continue
+		}
+		return err
+	}
+}
+
+func (t *TVDB) login(ctx context.Context) error {
+	body, err := json.Marshal(struct {
+		APIKey string `json:"apikey"`
+		PIN    string `json:"pin,omitempty"`
+	}{t.Key, t.PIN})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url("/login"), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	var resp struct {
+		Data struct {
+			Token string `json:"token"`
+		} `json:"data"`
+	}
+	if err = doJSON(t.Client, req, &resp); err != nil {
+		return err
+	}
+	t.token = resp.Data.Token
+	return nil
+}
+
+func (t *TVDB) url(path string) string {
+	base := t.BaseURL
+	if base == "" {
+		base = TVDBURL
+	}
+	return base + path
+}
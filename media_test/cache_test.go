@@ -0,0 +1,162 @@
+// Copyright 2024 Matthew P. Dargan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package media_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/matthewdargan/epify/media"
+)
+
+// A fakeProvider is a [media.MetadataProvider] that counts its lookups.
+type fakeProvider struct {
+	calls    int
+	err      error
+	shows    []media.Show
+	episodes map[string][]media.Episode
+}
+
+func (p *fakeProvider) SearchShows(_ context.Context, _ string) ([]media.Show, error) {
+	p.calls++
+	return p.shows, p.err
+}
+
+func (p *fakeProvider) SearchMovies(_ context.Context, _ string) ([]media.Movie, error) {
+	p.calls++
+	movies := make([]media.Movie, len(p.shows))
+	for i, s := range p.shows {
+		movies[i] = media.Movie{Show: s}
+	}
+	return movies, p.err
+}
+
+func (p *fakeProvider) Episodes(_ context.Context, id string) ([]media.Episode, error) {
+	p.calls++
+	return p.episodes[id], p.err
+}
+
+func TestCache(t *testing.T) {
+	t.Parallel()
+	dir, err := os.MkdirTemp("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p := &fakeProvider{
+		shows: []media.Show{
+			{Name: "The Office (UK)", Year: "2001", ID: "71256"},
+			{Name: "The Office (US)", Year: "2005", ID: "73244"},
+		},
+		episodes: map[string][]media.Episode{"73244": {{Season: 1, Number: 1, Name: "Pilot"}, {Season: 1, Number: 2}}},
+	}
+	ctx := context.Background()
+	c := &media.Cache{Dir: filepath.Join(dir, "tvdb"), Provider: p}
+	for range 2 {
+		shows, err := c.SearchShows(ctx, "The Office")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(shows, p.shows) {
+			t.Errorf("SearchShows() = %v, want %v", shows, p.shows)
+		}
+		es, err := c.Episodes(ctx, "73244")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(es, p.episodes["73244"]) {
+			t.Errorf("Episodes() = %v, want %v", es, p.episodes["73244"])
+		}
+	}
+	if p.calls != 2 {
+		t.Errorf("provider calls = %d, want 2", p.calls)
+	}
+	offline := &media.Cache{Dir: c.Dir}
+	s, err := media.LookupShow(ctx, offline, media.Show{Name: "the office", Year: "2005"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.ID != "73244" {
+		t.Errorf("LookupShow() = %+v, want ID 73244", s)
+	}
+	if _, err = offline.SearchShows(ctx, "Parks and Recreation"); !errors.Is(err, media.ErrNotCached) {
+		t.Errorf("SearchShows() error = %v, want %v", err, media.ErrNotCached)
+	}
+	shows, err := offline.Shows()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(shows, p.shows) {
+		t.Errorf("Shows() = %v, want %v", shows, p.shows)
+	}
+
+	// Expired entries are fetched again, and used if fetching fails.
+	c.MaxAge = -1
+	p.err = errors.New("offline")
+	if _, err = c.Episodes(ctx, "73244"); err != nil {
+		t.Errorf("Episodes() error = %v, want stale entry", err)
+	}
+	if _, err = c.Episodes(ctx, "71256"); err == nil {
+		t.Error("Episodes() error = nil, want provider error")
+	}
+	if p.calls != 4 {
+		t.Errorf("provider calls = %d, want 4", p.calls)
+	}
+
+	if err = c.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = offline.Episodes(ctx, "73244"); !errors.Is(err, media.ErrNotCached) {
+		t.Errorf("Episodes() error = %v, want %v", err, media.ErrNotCached)
+	}
+}
+
+func TestCacheWarm(t *testing.T) {
+	t.Parallel()
+	dir, err := os.MkdirTemp("", "warm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	library := filepath.Join(dir, "library")
+	for _, d := range []string{"The Office (2005) [tvdbid-73244]", "Unlabeled"} {
+		if err = os.MkdirAll(filepath.Join(library, d), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	setupFiles(t, library, "Braveheart (1995) [tmdbid-197].mkv", "notes.txt")
+	p := &fakeProvider{
+		shows:    []media.Show{{Name: "The Office", Year: "2005", ID: "73244"}},
+		episodes: map[string][]media.Episode{"73244": {{Season: 1, Number: 1}}},
+	}
+	ctx := context.Background()
+	c := &media.Cache{Dir: filepath.Join(dir, "cache"), Provider: p}
+	shows, err := c.WarmShows(ctx, library, "tvdbid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []media.Show{{Name: "The Office", Year: "2005", ID: "73244", Dir: library}}
+	if !slices.Equal(shows, want) {
+		t.Errorf("WarmShows() = %v, want %v", shows, want)
+	}
+	movies, err := c.WarmMovies(ctx, library, "tmdbid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(movies) != 1 || movies[0].Name != "Braveheart" || movies[0].Year != "1995" || movies[0].ID != "197" {
+		t.Errorf("WarmMovies() = %v, want Braveheart (1995) [tmdbid-197]", movies)
+	}
+	if p.calls != 3 {
+		t.Errorf("provider calls = %d, want 3", p.calls)
+	}
+	offline := &media.Cache{Dir: c.Dir}
+	if es, err := offline.Episodes(ctx, "73244"); err != nil || len(es) != 1 {
+		t.Errorf("Episodes() = %v, %v, want 1 cached episode", es, err)
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2024 Matthew P. Dargan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package media_test
+
+import (
+	"cmp"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/matthewdargan/epify/media"
+)
+
+func TestCheck(t *testing.T) {
+	t.Parallel()
+	library, err := os.MkdirTemp("", "check")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(library)
+	office := "The Office (2005) [tvdbid-73244]"
+	for _, d := range []string{
+		office + "/Season 01/Extras",
+		office + "/Season 2",
+		office + "/Specials",
+		office + "/Bonus",
+		office + "/Season 03/Junk",
+		"Shameless",
+		"Breaking Bad (2008)",
+		"Severance (2022) [imdbid-tt11280740] [tmdbid-95396]",
+		"Pluribus [tvdbid-458876]",
+	} {
+		if err = os.MkdirAll(filepath.Join(library, d), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	setupFiles(t, library,
+		"notes.txt",
+		office+"/tvshow.nfo",
+		office+"/poster.jpg",
+		office+"/The Office S01E01.mkv",
+		office+"/Season 01/The Office S01E01.mkv",
+		office+"/Season 01/The Office S01E01.en.srt",
+		office+"/Season 01/.The Office S01E01.mkv.epify-backup-0",
+		office+"/Season 01/The Office S01E02-E03.mkv",
+		office+"/Season 01/The Office S02E04.mkv",
+		office+"/Season 01/The Office S01E05-E05.mkv",
+		office+"/Season 01/ep06.mkv",
+		office+"/Season 01/The Office S01E07.en.srt",
+		office+"/Season 01/season.jpg",
+		office+"/Season 01/season.nfo",
+		office+"/Season 01/Extras/blooper.mkv",
+		office+"/Season 01/download.torrent",
+		office+"/Season 2/The Office S02E01.mkv",
+		office+"/Specials/The Office S00E01.mkv",
+	)
+	got, err := media.Check(library)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []media.Problem{
+		{Kind: media.BadShowDir, Path: "Breaking Bad (2008)", Message: "missing [tvdbid-N], [tmdbid-N], or [imdbid-ttN] tag"},
+		{Kind: media.BadShowDir, Path: "Shameless", Message: "missing [tvdbid-N], [tmdbid-N], or [imdbid-ttN] tag"},
+		{Kind: media.BadSeasonDir, Path: office + "/Bonus", Message: `not labeled like "Season 01" or "Specials"`},
+		{Kind: media.BadSeasonDir, Path: office + "/Season 2", Message: "season number must have at least two digits"},
+		{Kind: media.BadEpisode, Path: office + "/The Office S01E01.mkv", Message: "not in a season directory"},
+		{Kind: media.StrayFile, Path: office + "/Season 01/The Office S01E07.en.srt", Message: "no episode for sidecar"},
+		{Kind: media.StrayFile, Path: office + "/Season 01/download.torrent", Message: "not a video, sidecar, or artwork"},
+		{Kind: media.BadEpisode, Path: office + "/Season 01/ep06.mkv", Message: "missing SxxEyy"},
+		{Kind: media.BadEpisode, Path: office + "/Season 01/The Office S01E05-E05.mkv", Message: "invalid episode range 5-5"},
+		{Kind: media.BadEpisode, Path: office + "/Season 01/The Office S02E04.mkv", Message: "season 2 in season 1 directory"},
+		{Kind: media.StrayFile, Path: office + "/Season 03/Junk", Message: "unexpected directory"},
+		{Kind: media.StrayFile, Path: "notes.txt", Message: "not in a show directory"},
+	}
+	for i := range got {
+		got[i].Path, _ = filepath.Rel(library, got[i].Path)
+		got[i].Path = filepath.ToSlash(got[i].Path)
+	}
+	sortProblems(got)
+	sortProblems(want)
+	if !slices.Equal(got, want) {
+		t.Errorf("Check(%q) = %v, want %v", library, got, want)
+	}
+	b, err := json.Marshal(want[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p media.Problem
+	if err = json.Unmarshal(b, &p); err != nil {
+		t.Fatal(err)
+	}
+	if p != want[0] {
+		t.Errorf("json.Unmarshal(%s) = %v, want %v", b, p, want[0])
+	}
+}
+
+func sortProblems(ps []media.Problem) {
+	slices.SortFunc(ps, func(a, b media.Problem) int {
+		return cmp.Or(strings.Compare(a.Path, b.Path), strings.Compare(a.Message, b.Message))
+	})
+}
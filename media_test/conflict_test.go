@@ -0,0 +1,402 @@
+// Copyright 2024 Matthew P. Dargan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package media_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/matthewdargan/epify/media"
+)
+
+func TestConflict(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name      string
+		policy    media.ConflictPolicy
+		mode      media.OpKind
+		want      []string
+		conflicts []string
+	}{
+		{
+			name:      "fail",
+			policy:    media.Fail,
+			conflicts: []string{"Lost S01E01.mkv", "Lost S01E02.mkv"},
+		},
+		{
+			name:   "skip",
+			policy: media.Skip,
+			want: []string{
+				"Lost S01E01.mkv", "Lost S01E02.en.srt", "Lost S01E02.mkv", "Lost S01E03.en.srt", "Lost S01E03.mkv",
+			},
+		},
+		{
+			name:   "suffix",
+			policy: media.Suffix,
+			want: []string{
+				"Lost S01E01 (1).en.srt", "Lost S01E01 (1).mkv", "Lost S01E01.mkv",
+				"Lost S01E02 (1).mkv", "Lost S01E02.en.srt", "Lost S01E02.mkv",
+				"Lost S01E03.en.srt", "Lost S01E03.mkv",
+			},
+		},
+		{
+			name:   "replace larger",
+			policy: media.ReplaceLarger,
+			want: []string{
+				".Lost S01E01.mkv.epify-backup-0", "Lost S01E01.en.srt", "Lost S01E01.mkv",
+				"Lost S01E02.en.srt", "Lost S01E02.mkv", "Lost S01E03.en.srt", "Lost S01E03.mkv",
+			},
+		},
+		{
+			name:   "replace larger link",
+			policy: media.ReplaceLarger,
+			mode:   media.Link,
+			want: []string{
+				".Lost S01E01.mkv.epify-backup-0", "Lost S01E01.en.srt", "Lost S01E01.mkv",
+				"Lost S01E02.en.srt", "Lost S01E02.mkv", "Lost S01E03.en.srt", "Lost S01E03.mkv",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			dir, err := os.MkdirTemp("", "conflict")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			seasonDir := filepath.Join(dir, "Lost (2004) [tvdbid-73739]", "Season 01")
+			if err = os.MkdirAll(seasonDir, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			writeFile(t, filepath.Join(seasonDir, "Lost S01E01.mkv"), "old")
+			writeFile(t, filepath.Join(seasonDir, "Lost S01E02.mkv"), "old episode")
+			writeFile(t, filepath.Join(seasonDir, "Lost S01E02.en.srt"), "old")
+			writeFile(t, filepath.Join(seasonDir, "Lost S01E03.mkv"), "old")
+			writeFile(t, filepath.Join(seasonDir, "Lost S01E03.en.srt"), "old")
+			writeFile(t, filepath.Join(dir, "ep1.mkv"), "new episode")
+			writeFile(t, filepath.Join(dir, "ep1.en.srt"), "new")
+			writeFile(t, filepath.Join(dir, "ep2.mkv"), "new")
+			a := media.Addition{
+				SeasonDir: seasonDir,
+				Episodes:  []string{filepath.Join(dir, "ep1.mkv"), filepath.Join(dir, "ep2.mkv")},
+				Preserve:  true,
+			}
+			_, err = media.AddEpisodes(context.Background(), a, media.Options{Mode: tt.mode, Conflict: tt.policy})
+			if len(tt.conflicts) > 0 {
+				var got []string
+				for _, e := range unwrapAll(err) {
+					var ce *media.ConflictError
+					if errors.As(e, &ce) {
+						got = append(got, filepath.Base(ce.Dst))
+					}
+				}
+				if !slices.Equal(got, tt.conflicts) {
+					t.Errorf("AddEpisodes(%v) conflicts = %v, want %v", a, got, tt.conflicts)
+				}
+				if _, err = os.Stat(filepath.Join(dir, "ep1.mkv")); err != nil {
+					t.Errorf("AddEpisodes(%v) moved episodes despite conflicts", a)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			ents, err := os.ReadDir(seasonDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, ent := range ents {
+				got = append(got, ent.Name())
+			}
+			want := slices.Clone(tt.want)
+			slices.Sort(want)
+			if !slices.Equal(got, want) {
+				t.Errorf("AddEpisodes(%v) = %v, want %v", a, got, want)
+			}
+			if tt.policy == media.ReplaceLarger {
+				for name, want := range map[string]string{
+					"Lost S01E01.mkv":    "new episode",
+					"Lost S01E01.en.srt": "new",
+					"Lost S01E02.mkv":    "old episode",
+				} {
+					if b, _ := os.ReadFile(filepath.Join(seasonDir, name)); string(b) != want {
+						t.Errorf("%s = %q, want %q", name, b, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestConflictQuality(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		existing []byte
+		ep       string
+		content  []byte
+		replaced bool
+	}{
+		{
+			name:     "higher resolution",
+			existing: mkv(1080, 100),
+			ep:       "Lost.S01E01.2160p.WEB-DL.mkv",
+			content:  []byte("new"),
+			replaced: true,
+		},
+		{
+			name:     "lower resolution",
+			existing: mkv(2160, 0),
+			ep:       "Lost.S01E01.1080p.BluRay.mkv",
+			content:  make([]byte, 200),
+		},
+		{
+			name:     "mp4 resolution",
+			existing: mp4(720, 100),
+			ep:       "Lost.S01E01.1080p.mp4",
+			content:  []byte("new"),
+			replaced: true,
+		},
+		{
+			name:     "same resolution",
+			existing: mkv(1080, 0),
+			ep:       "Lost.S01E01.1080p.HDTV.mkv",
+			content:  make([]byte, 200),
+			replaced: true,
+		},
+		{
+			name:     "unknown resolution",
+			existing: make([]byte, 100),
+			ep:       "Lost.S01E01.2160p.mkv",
+			content:  []byte("new"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			dir, err := os.MkdirTemp("", "conflict")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			seasonDir := filepath.Join(dir, "Lost (2004) [tvdbid-73739]", "Season 01")
+			if err = os.MkdirAll(seasonDir, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			existing := filepath.Join(seasonDir, "Lost S01E01"+filepath.Ext(tt.ep))
+			writeFile(t, existing, string(tt.existing))
+			ep := filepath.Join(dir, tt.ep)
+			writeFile(t, ep, string(tt.content))
+			m, err := media.ParseMatcher("sxxeyy")
+			if err != nil {
+				t.Fatal(err)
+			}
+			a := media.Addition{SeasonDir: seasonDir, Episodes: []string{ep}, Match: m, Preserve: true}
+			if _, err = media.AddEpisodes(context.Background(), a, media.Options{Conflict: media.ReplaceLarger}); err != nil {
+				t.Fatal(err)
+			}
+			b, err := os.ReadFile(existing)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if replaced := bytes.Equal(b, tt.content); replaced != tt.replaced {
+				t.Errorf("AddEpisodes(%v) replaced = %t, want %t", a, replaced, tt.replaced)
+			}
+		})
+	}
+}
+
+// mkv returns a Matroska file holding a video track of the given height,
+// followed by pad bytes of media.
+func mkv(height, pad int) []byte {
+	el := func(id uint32, payload ...[]byte) []byte {
+		b := binary.BigEndian.AppendUint32(nil, id)
+		b = bytes.TrimLeft(b, "\x00")
+		n := len(bytes.Join(payload, nil))
+		b = append(b, 0x01)
+		b = append(b, binary.BigEndian.AppendUint64(nil, uint64(n))[1:]...)
+		return append(b, bytes.Join(payload, nil)...)
+	}
+	return slices.Concat(
+		el(0x1A45DFA3),
+		el(0x18538067,
+			el(0x1654AE6B, el(0xAE, el(0xE0, el(0xBA, binary.BigEndian.AppendUint16(nil, uint16(height)))))),
+			el(0x1F43B675, make([]byte, pad)),
+		),
+	)
+}
+
+// mp4 returns an MP4 file holding a video track of the given height,
+// followed by pad bytes of media.
+func mp4(height, pad int) []byte {
+	box := func(typ string, payload ...[]byte) []byte {
+		p := bytes.Join(payload, nil)
+		return slices.Concat(binary.BigEndian.AppendUint32(nil, uint32(8+len(p))), []byte(typ), p)
+	}
+	tkhd := binary.BigEndian.AppendUint32(make([]byte, 80), uint32(height)<<16)
+	return slices.Concat(box("ftyp", []byte("isom")), box("moov", box("trak", box("tkhd", tkhd))), box("mdat", make([]byte, pad)))
+}
+
+func TestConflictUndoReplace(t *testing.T) {
+	t.Parallel()
+	dir, err := os.MkdirTemp("", "conflict")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	seasonDir := filepath.Join(dir, "Lost (2004) [tvdbid-73739]", "Season 01")
+	if err = os.MkdirAll(seasonDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	existing := filepath.Join(seasonDir, "Lost S01E01.mkv")
+	writeFile(t, existing, "old")
+	ep := filepath.Join(dir, "ep1.mkv")
+	writeFile(t, ep, "new episode")
+	opts := media.Options{Conflict: media.ReplaceLarger}
+	p, err := media.PlanAddition(media.Addition{SeasonDir: seasonDir, Episodes: []string{ep}, Preserve: true}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j := media.Journal{Path: filepath.Join(dir, "journal")}
+	e, err := j.Apply(context.Background(), p, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(existing); string(b) != "new episode" {
+		t.Fatalf("Apply(%v) left %q = %q", p, existing, b)
+	}
+	inv, err := e.Inverse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = j.Undo(context.Background(), inv, e.ID, media.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(existing); string(b) != "old" {
+		t.Errorf("Undo(%v) left %q = %q, want %q", inv, existing, b, "old")
+	}
+	if b, _ := os.ReadFile(ep); string(b) != "new episode" {
+		t.Errorf("Undo(%v) left %q = %q, want %q", inv, ep, b, "new episode")
+	}
+	if ents, _ := os.ReadDir(seasonDir); len(ents) != 1 {
+		t.Errorf("Undo(%v) left %d files in %q, want 1", inv, len(ents), seasonDir)
+	}
+}
+
+func TestConflictSuffixed(t *testing.T) {
+	t.Parallel()
+	dir, err := os.MkdirTemp("", "suffix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	seasonDir := filepath.Join(dir, "Lost (2004) [tvdbid-73739]", "Season 01")
+	if err = os.MkdirAll(seasonDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	setupFiles(t, seasonDir, "Lost S01E02.mkv", "Lost S01E02 (1).mkv")
+	a := media.Addition{SeasonDir: seasonDir, Episodes: setupFiles(t, dir, "ep2.mkv"), Preserve: true}
+	p, err := media.PlanAddition(a, media.Options{Conflict: media.Suffix})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := filepath.Base(p.Ops[0].Dst), "Lost S01E02 (2).mkv"; got != want {
+		t.Errorf("PlanAddition(%v) = %q, want %q", a, got, want)
+	}
+}
+
+func TestConflictSuffixedAdd(t *testing.T) {
+	t.Parallel()
+	dir, err := os.MkdirTemp("", "suffixadd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	showDir := filepath.Join(dir, "shows", "Lost (2004) [tvdbid-73739]")
+	seasonDir := filepath.Join(showDir, "Season 01")
+	if err = os.MkdirAll(seasonDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, step := range []struct {
+		episode string
+		opts    media.Options
+		want    string
+	}{
+		{episode: "ep1.mkv", want: "Lost S01E01.mkv"},
+		{episode: "ep1.proper.mkv", opts: media.Options{Conflict: media.Suffix}, want: "Lost S01E01 (1).mkv"},
+		{episode: "ep2.mkv", want: "Lost S01E02.mkv"},
+	} {
+		a := media.Addition{SeasonDir: seasonDir, Episodes: setupFiles(t, dir, step.episode)}
+		if step.opts.Conflict == media.Suffix {
+			a.Preserve = true
+		}
+		if _, err = media.AddEpisodes(context.Background(), a, step.opts); err != nil {
+			t.Fatalf("AddEpisodes(%v) error = %v", a, err)
+		}
+		if _, err = os.Stat(filepath.Join(seasonDir, step.want)); err != nil {
+			t.Errorf("AddEpisodes(%v) did not place %q", a, step.want)
+		}
+	}
+	ps, err := media.Check(filepath.Join(dir, "shows"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ps) != 0 {
+		t.Errorf("Check() = %v, want no problems", ps)
+	}
+	rs, err := media.Gaps(showDir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rs) != 1 || !slices.Equal(rs[0].Duplicates, []int{1}) {
+		t.Errorf("Gaps() = %v, want duplicate E01", rs)
+	}
+}
+
+func TestApplyConflict(t *testing.T) {
+	t.Parallel()
+	dir, err := os.MkdirTemp("", "apply")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := setupFiles(t, dir, "a.mkv", "b.mkv")
+	for _, k := range []media.OpKind{media.Rename, media.Copy, media.Symlink} {
+		p := media.Plan{Ops: []media.Op{{Kind: k, Src: files[0], Dst: files[1]}}}
+		var ce *media.ConflictError
+		if _, err = p.Apply(context.Background(), media.Options{}); !errors.As(err, &ce) {
+			t.Errorf("Apply(%v) error = %v, want ConflictError", p, err)
+		}
+	}
+	if _, err = os.Stat(files[0]); err != nil {
+		t.Errorf("Apply moved %q despite conflict", files[0])
+	}
+}
+
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// unwrapAll returns the errors joined in err.
+func unwrapAll(err error) []error {
+	if u, ok := err.(interface{ Unwrap() []error }); ok {
+		return u.Unwrap()
+	}
+	if err == nil {
+		return nil
+	}
+	return []error{err}
+}
@@ -0,0 +1,77 @@
+// Copyright 2024 Matthew P. Dargan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package media_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/matthewdargan/epify/media"
+)
+
+func TestGaps(t *testing.T) {
+	t.Parallel()
+	dir, err := os.MkdirTemp("", "gaps")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	show := filepath.Join(dir, "Show (2018) [tvdbid-1]")
+	for _, d := range []string{"Season 01", "Season 02", "Season 03", "Specials", "Extras"} {
+		if err = os.MkdirAll(filepath.Join(show, d), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	setupFiles(t, show,
+		"Season 01/Show S01E01.mkv",
+		"Season 01/Show S01E02-E03.mkv",
+		"Season 01/Show S01E03.mkv",
+		"Season 01/Show S01E03.en.srt",
+		"Season 01/Show S01E06.mkv",
+		"Season 01/Show S01E09.mkv",
+		"Season 01/Show S02E12.mkv",
+		"Season 02/Show S02E01.mkv",
+		"Season 02/Show S02E02-part1.mkv",
+		"Season 02/Show S02E02-part2.mkv",
+		"Season 03/Show S03E01.mkv",
+		"Specials/Show S00E02.mkv",
+	)
+	want := []media.GapReport{
+		{Dir: filepath.Join(show, "Season 01"), Season: 1, Missing: []int{4, 5, 7, 8}, Duplicates: []int{3}},
+		{Dir: filepath.Join(show, "Specials"), Season: 0, Missing: []int{1}},
+	}
+	got, err := media.Gaps(show, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Gaps(%q, nil) = %v, want %v", show, got, want)
+	}
+	es := []media.Episode{
+		{Season: 2, Number: 1, Aired: "2019-01-01"},
+		{Season: 2, Number: 2, Aired: "2019-01-08"},
+		{Season: 2, Number: 3, Aired: "2019-01-15"},
+		{Season: 2, Number: 4, Aired: "2019-01-22"},
+		{Season: 3, Number: 1, Aired: "2020-01-01"},
+		{Season: 3, Number: 2},
+		{Season: 3, Number: 3, Aired: "9999-01-01"},
+	}
+	want = []media.GapReport{
+		want[0],
+		{Dir: filepath.Join(show, "Season 02"), Season: 2, MissingEnd: []int{3, 4}},
+		want[1],
+	}
+	if got, err = media.Gaps(show, es); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Gaps(%q, %v) = %v, want %v", show, es, got, want)
+	}
+	if s, want := got[0].String(), filepath.Join(show, "Season 01")+": missing E04-E05, E07-E08; duplicate E03"; s != want {
+		t.Errorf("GapReport.String() = %q, want %q", s, want)
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2024 Matthew P. Dargan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package media_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/matthewdargan/epify/media"
+)
+
+func TestParseRelease(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		release string
+		want    media.Release
+		wantErr bool
+	}{
+		{
+			name:    "scene",
+			release: "Show.Name.S03E07.1080p.WEB.mkv",
+			want:    media.Release{Show: "Show Name", Season: 3, Episode: 7, Last: 7},
+		},
+		{
+			name:    "lower case",
+			release: "show_name_s01e10_720p.mkv",
+			want:    media.Release{Show: "show name", Season: 1, Episode: 10, Last: 10},
+		},
+		{
+			name:    "multiple episodes",
+			release: "Show.Name.S01E01E02.mkv",
+			want:    media.Release{Show: "Show Name", Season: 1, Episode: 1, Last: 2},
+		},
+		{
+			name:    "episode range",
+			release: "Show Name S02E03-E05 [1080p].mkv",
+			want:    media.Release{Show: "Show Name", Season: 2, Episode: 3, Last: 5},
+		},
+		{
+			name:    "cross",
+			release: "Show Name - 3x07 - Title.mkv",
+			want:    media.Release{Show: "Show Name", Season: 3, Episode: 7, Last: 7},
+		},
+		{
+			name:    "number in name",
+			release: "9-1-1.S04E02.mkv",
+			want:    media.Release{Show: "9-1-1", Season: 4, Episode: 2, Last: 2},
+		},
+		{
+			name:    "year in name",
+			release: "Shameless.US.2011.S01E01.mkv",
+			want:    media.Release{Show: "Shameless US 2011", Season: 1, Episode: 1, Last: 1},
+		},
+		{
+			name:    "missing episode",
+			release: "Show.Name.1080p.mkv",
+			wantErr: true,
+		},
+		{
+			name:    "reversed range",
+			release: "Show.Name.S01E05E02.mkv",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := media.ParseRelease(tt.release)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseRelease(%q) error = %v", tt.release, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseRelease(%q) = %+v, want %+v", tt.release, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImportEpisodes(t *testing.T) {
+	t.Parallel()
+	dir, err := os.MkdirTemp("", "import")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	library := filepath.Join(dir, "shows")
+	shameless := filepath.Join(library, "Shameless (2011) [tvdbid-161511]")
+	office := filepath.Join(library, "The Office (2005) [tvdbid-73244]")
+	for _, d := range []string{filepath.Join(shameless, "Season 01"), office} {
+		if err = os.MkdirAll(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	setupFiles(t, filepath.Join(shameless, "Season 01"), "Shameless S01E01.mkv")
+	downloads := filepath.Join(dir, "downloads")
+	release := filepath.Join(downloads, "The.Office.S03E01.1080p.WEB")
+	if err = os.MkdirAll(filepath.Join(release, "Sample"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	setupFiles(t, release, "The.Office.S03E01.1080p.WEB.mkv", "The.Office.S03E01.1080p.WEB.en.srt", "release.nfo",
+		"Sample/the.office.s03e01.sample.mkv")
+	files := setupFiles(t, downloads, "Shameless.2011.S01E03.mkv", "The.Office.S02E05.mkv")
+	im := media.Import{Library: library, Paths: append(files, release)}
+	if _, err = media.ImportEpisodes(context.Background(), im, media.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"Shameless (2011) [tvdbid-161511]/Season 01/Shameless S01E01.mkv",
+		"Shameless (2011) [tvdbid-161511]/Season 01/Shameless S01E03.mkv",
+		"The Office (2005) [tvdbid-73244]/Season 02/The Office S02E05.mkv",
+		"The Office (2005) [tvdbid-73244]/Season 03/The Office S03E01.en.srt",
+		"The Office (2005) [tvdbid-73244]/Season 03/The Office S03E01.mkv",
+	}
+	var got []string
+	err = filepath.WalkDir(library, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			rel, _ := filepath.Rel(library, path)
+			got = append(got, filepath.ToSlash(rel))
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("ImportEpisodes(%v) = %v, want %v", im, got, want)
+	}
+	im.Paths = setupFiles(t, downloads, "Unknown.Show.S01E01.mkv")
+	if _, err = media.ImportEpisodes(context.Background(), im, media.Options{}); err == nil {
+		t.Errorf("ImportEpisodes(%v) error = nil, want unknown show error", im)
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2024 Matthew P. Dargan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package media_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/matthewdargan/epify/media"
+)
+
+func TestUpdates(t *testing.T) {
+	t.Parallel()
+	p := media.Plan{Ops: []media.Op{
+		{Kind: media.Mkdir, Dst: "/shows/Show (2018) [tvdbid-1]/Season 02"},
+		{Kind: media.Rename, Src: "/downloads/ep1.mkv", Dst: "/shows/Show (2018) [tvdbid-1]/Season 02/Show S02E01.mkv"},
+		{Kind: media.Link, Src: "/downloads/ep1.en.srt", Dst: "/shows/Show (2018) [tvdbid-1]/Season 02/Show S02E01.en.srt"},
+		{Kind: media.Copy, Src: "/downloads/ep5.mkv", Dst: "/shows/Show (2018) [tvdbid-1]/Season 01/Show S01E05.mkv"},
+		{Kind: media.Remove, Dst: "/shows/Show (2018) [tvdbid-1]/Season 03"},
+	}}
+	want := []media.MediaUpdate{
+		{Path: "/shows/Show (2018) [tvdbid-1]/Season 02", UpdateType: "Created"},
+		{Path: "/shows/Show (2018) [tvdbid-1]/Season 01/Show S01E05.mkv", UpdateType: "Created"},
+		{Path: "/shows/Show (2018) [tvdbid-1]/Season 03", UpdateType: "Deleted"},
+	}
+	if got := media.Updates(p); !slices.Equal(got, want) {
+		t.Errorf("Updates(%v) = %v, want %v", p, got, want)
+	}
+
+	// Undoing a placement reports the library paths it removes, not the
+	// downloads it restores.
+	p = media.Plan{Ops: []media.Op{
+		{Kind: media.Rename, Src: "/movies/Film (2018) [tmdbid-2].mkv", Dst: "/downloads/film.mkv"},
+		{Kind: media.Remove, Dst: "/shows/Show [tvdbid-1]/Season 02/Show S02E01.mkv"},
+		{Kind: media.Remove, Dst: "/shows"},
+	}}
+	want = []media.MediaUpdate{
+		{Path: "/movies/Film (2018) [tmdbid-2].mkv", UpdateType: "Deleted"},
+		{Path: "/shows/Show [tvdbid-1]/Season 02/Show S02E01.mkv", UpdateType: "Deleted"},
+	}
+	if got := media.Updates(p); !slices.Equal(got, want) {
+		t.Errorf("Updates(%v) = %v, want %v", p, got, want)
+	}
+}
+
+func TestJellyfin(t *testing.T) {
+	t.Parallel()
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Authorization") != `MediaBrowser Token="key"` {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/Library/Refresh":
+			got = append(got, "refresh")
+		case "/Library/Media/Updated":
+			var body struct {
+				Updates []struct {
+					Path       string
+					UpdateType string
+				}
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			for _, u := range body.Updates {
+				got = append(got, u.UpdateType+" "+u.Path)
+			}
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+	jf := &media.Jellyfin{URL: srv.URL + "/", Key: "key"}
+	if err := jf.RefreshLibrary(ctx); err != nil {
+		t.Fatal(err)
+	}
+	p := media.Plan{Ops: []media.Op{
+		{Kind: media.Mkdir, Dst: "/shows/Show (2018) [tvdbid-1]"},
+		{Kind: media.Link, Src: "/downloads/ep1.mkv", Dst: "/shows/Other (2020) [tvdbid-2]/Season 01/Other S01E01.mkv"},
+	}}
+	if err := jf.Refresh(ctx, p); err != nil {
+		t.Fatal(err)
+	}
+	if err := jf.Refresh(ctx, media.Plan{}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"refresh",
+		"Created /shows/Show (2018) [tvdbid-1]",
+		"Created /shows/Other (2020) [tvdbid-2]/Season 01/Other S01E01.mkv",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("requests = %v, want %v", got, want)
+	}
+	jf.Key = "wrong"
+	err := jf.Refresh(ctx, p)
+	if e, ok := err.(*media.APIError); !ok || e.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Refresh() error = %v, want unauthorized APIError", err)
+	}
+}
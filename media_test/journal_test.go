@@ -0,0 +1,118 @@
+// Copyright 2024 Matthew P. Dargan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package media_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/matthewdargan/epify/media"
+)
+
+func TestJournal(t *testing.T) {
+	t.Parallel()
+	dir, err := os.MkdirTemp("", "journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	showDir := filepath.Join(dir, "Monster (2004) [tvdbid-79118]")
+	if err = os.Mkdir(showDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	eps := setupFiles(t, dir, "ep1.mkv", "ep2.mkv")
+	j := media.Journal{Path: filepath.Join(dir, "state", "journal.jsonl")}
+	p, err := media.PlanSeason(media.Season{N: "1", ShowDir: showDir, Episodes: eps}, media.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := j.Apply(context.Background(), p, media.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.ID != 1 || len(e.Ops) != len(p.Ops) {
+		t.Errorf("Apply(%v) = %v, want entry 1 with %d operations", p, e, len(p.Ops))
+	}
+	got, err := j.Undoable(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != e.ID {
+		t.Errorf("Undoable(0) = %v, want %v", got.ID, e.ID)
+	}
+	inv, err := got.Inverse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := j.Undo(context.Background(), inv, got.ID, media.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.ID != 2 || u.Undo != 1 {
+		t.Errorf("Undo(%v) = %v, want entry 2 undoing 1", got, u)
+	}
+	for _, ep := range eps {
+		if _, err := os.Stat(ep); err != nil {
+			t.Errorf("Undo(%v) = %v, want %v", got, err, ep)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(showDir, "Season 01")); !os.IsNotExist(err) {
+		t.Errorf("Undo(%v) left season directory", got)
+	}
+	if _, err := j.Undoable(0); err == nil {
+		t.Error("Undoable(0) error = nil, want no entry")
+	}
+	if _, err := j.Undoable(1); err == nil {
+		t.Error("Undoable(1) error = nil, want already undone")
+	}
+	es, err := j.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(es) != 2 {
+		t.Fatalf("Entries() = %v, want 2 entries", es)
+	}
+	if es[0].UndoneBy != 2 || es[1].UndoneBy != 0 {
+		t.Errorf("Entries() undone by %d and %d, want 2 and 0", es[0].UndoneBy, es[1].UndoneBy)
+	}
+}
+
+func TestJournalConcurrent(t *testing.T) {
+	t.Parallel()
+	dir, err := os.MkdirTemp("", "concurrent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	j := media.Journal{Path: filepath.Join(dir, "journal.jsonl")}
+	const n = 16
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p := media.Plan{Ops: []media.Op{{Kind: media.Mkdir, Dst: filepath.Join(dir, strconv.Itoa(i))}}}
+			if _, err := j.Apply(context.Background(), p, media.Options{}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	es, err := j.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make(map[int]bool)
+	for _, e := range es {
+		ids[e.ID] = true
+	}
+	if len(es) != n || len(ids) != n {
+		t.Errorf("Entries() = %d entries with %d IDs, want %d unique", len(es), len(ids), n)
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2024 Matthew P. Dargan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build unix
+
+package media_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/matthewdargan/epify/media"
+)
+
+func TestJournalInterrupted(t *testing.T) {
+	t.Parallel()
+	dir, err := os.MkdirTemp("", "interrupt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	eps := setupFiles(t, dir, "ep1.mkv", "ep3.mkv")
+	seasonDir := filepath.Join(dir, "Season 01")
+	p := media.Plan{Ops: []media.Op{
+		{Kind: media.Mkdir, Dst: seasonDir},
+		{Kind: media.Rename, Src: eps[0], Dst: filepath.Join(seasonDir, "Show S01E01.mkv")},
+		{Kind: media.Copy, Src: fifo(t, dir, "ep2.mkv"), Dst: filepath.Join(seasonDir, "Show S01E02.mkv")},
+		{Kind: media.Remove, Dst: eps[1]},
+	}}
+	j := media.Journal{Path: filepath.Join(dir, "journal.jsonl")}
+	ctx, cancel := context.WithCancel(context.Background())
+	go interrupt(t, p.Ops[2].Src, cancel)
+	e, err := j.Apply(ctx, p, media.Options{Concurrency: 1})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Apply(%v) error = %v, want context canceled", p, err)
+	}
+	if len(e.Ops) != 2 {
+		t.Errorf("Apply(%v) recorded %v, want mkdir and rename", p, e.Ops)
+	}
+	if _, err = os.Stat(p.Ops[1].Dst); err != nil {
+		t.Errorf("Apply(%v) reversed completed rename: %v", p, err)
+	}
+	if ents, _ := os.ReadDir(seasonDir); len(ents) != 1 {
+		t.Errorf("Apply(%v) left %d files in %q, want 1", p, len(ents), seasonDir)
+	}
+	if _, err = os.Stat(eps[1]); err != nil {
+		t.Errorf("Apply(%v) removed %q after cancellation", p, eps[1])
+	}
+
+	// An interrupted undo is recorded as an ordinary entry.
+	inv, err := e.Inverse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Undo the rename, then block copying until canceled.
+	u := media.Plan{Ops: []media.Op{
+		inv.Ops[0],
+		{Kind: media.Copy, Src: fifo(t, dir, "ep4.mkv"), Dst: filepath.Join(seasonDir, "Show S01E04.mkv")},
+		inv.Ops[1],
+	}}
+	ctx, cancel = context.WithCancel(context.Background())
+	go interrupt(t, u.Ops[1].Src, cancel)
+	ue, err := j.Undo(ctx, u, e.ID, media.Options{Concurrency: 1})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Undo(%v) error = %v, want context canceled", u, err)
+	}
+	if ue.Undo != 0 || len(ue.Ops) != 1 {
+		t.Errorf("Undo(%v) = %v, want ordinary entry with rename", u, ue)
+	}
+	if got, err := j.Undoable(0); err != nil || got.ID != ue.ID {
+		t.Errorf("Undoable(0) = %v, %v, want %v", got.ID, err, ue.ID)
+	}
+	if _, err = j.Undoable(e.ID); err != nil {
+		t.Errorf("Undoable(%d) error = %v, want undoable", e.ID, err)
+	}
+}
+
+func TestJournalUndoInterrupted(t *testing.T) {
+	t.Parallel()
+	dir, err := os.MkdirTemp("", "interrupt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	eps := setupFiles(t, dir, "ep1.mkv")
+	writeFile(t, eps[0], "episode")
+	seasonDir := filepath.Join(dir, "Season 01")
+	ep := filepath.Join(seasonDir, "Show S01E01.mkv")
+	p := media.Plan{Ops: []media.Op{
+		{Kind: media.Mkdir, Dst: seasonDir},
+		{Kind: media.Copy, Src: eps[0], Dst: ep},
+	}}
+	j := media.Journal{Path: filepath.Join(dir, "journal.jsonl")}
+	e, err := j.Apply(context.Background(), p, media.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv, err := e.Inverse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Remove the episode and season directory, then block copying into the
+	// vacated directory path until canceled.
+	u := media.Plan{Ops: append(inv.Ops, media.Op{Kind: media.Copy, Src: fifo(t, dir, "ep2.mkv"), Dst: seasonDir})}
+	ctx, cancel := context.WithCancel(context.Background())
+	go interrupt(t, u.Ops[2].Src, cancel)
+	ue, err := j.Undo(ctx, u, e.ID, media.Options{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Undo(%v) error = %v, want context canceled", u, err)
+	}
+	if ue.Undo != 0 || len(ue.Ops) != 2 {
+		t.Fatalf("Undo(%v) = %v, want ordinary entry with two removals", u, ue)
+	}
+
+	// The interrupted undo can itself be undone, restoring the episode.
+	uinv, err := ue.Inverse()
+	if err != nil {
+		t.Fatalf("Inverse(%v) error = %v", ue, err)
+	}
+	if _, err = j.Undo(context.Background(), uinv, ue.ID, media.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(ep); string(b) != "episode" {
+		t.Errorf("Undo(%v) left %q = %q, want %q", uinv, ep, b, "episode")
+	}
+	if _, err = j.Undoable(e.ID); err != nil {
+		t.Errorf("Undoable(%d) error = %v, want undoable", e.ID, err)
+	}
+	if _, err = j.Undo(context.Background(), inv, e.ID, media.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if ents, _ := os.ReadDir(dir); len(ents) != 3 {
+		t.Errorf("Undo(%v) left %d files, want ep1.mkv, ep2.mkv, and the journal", inv, len(ents))
+	}
+}
+
+// fifo creates a named pipe called name in dir and returns its path.
+func fifo(t *testing.T, dir, name string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := syscall.Mkfifo(path, 0o644); err != nil {
+		t.Skipf("mkfifo unsupported: %v", err)
+	}
+	return path
+}
+
+// interrupt writes to the named pipe at path, calls cancel while the reader
+// waits for more, and then writes again so that the reader wakes up to find
+// its context canceled.
+func interrupt(t *testing.T, path string, cancel context.CancelFunc) {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Error(err)
+		cancel()
+		return
+	}
+	defer f.Close()
+	f.Write([]byte("partial"))
+	cancel()
+	f.Write([]byte("rest"))
+}
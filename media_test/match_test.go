@@ -0,0 +1,139 @@
+// Copyright 2024 Matthew P. Dargan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package media_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/matthewdargan/epify/media"
+)
+
+func TestParseMatcher(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		s       string
+		wantErr bool
+	}{
+		{s: "sxxeyy"},
+		{s: "nxnn"},
+		{s: "episode"},
+		{s: `ep(?P<episode>\d+)(?:-pt(?P<part>\d))?`},
+		{s: `S(?P<season>\d+)E(?P<episode>\d+)`},
+		{s: `(\d+)`, wantErr: true},
+		{s: `(?P<episode>\d+`, wantErr: true},
+		{s: "SxxEyy", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			t.Parallel()
+			m, err := media.ParseMatcher(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseMatcher(%q) error = %v", tt.s, err)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := m.String(); got != tt.s {
+				t.Errorf("ParseMatcher(%q).String() = %q", tt.s, got)
+			}
+			var u media.Matcher
+			if err = u.UnmarshalText([]byte(tt.s)); err != nil || u.String() != tt.s {
+				t.Errorf("UnmarshalText(%q) = %q, %v", tt.s, u, err)
+			}
+		})
+	}
+}
+
+func TestMatcher(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		match    string
+		episodes []string
+		preserve bool
+		want     []string
+		wantErr  bool
+	}{
+		{
+			name:     "number in title",
+			match:    "sxxeyy",
+			episodes: []string{"24.S01E02.1080p.mkv", "24.S01E01.720p.mkv", "24.S01E10.2160p.mkv"},
+			preserve: true,
+			want:     []string{"Lost S01E01.mkv", "Lost S01E02.mkv", "Lost S01E10.mkv"},
+		},
+		{
+			name:     "multiple episodes",
+			match:    "sxxeyy",
+			episodes: []string{"Show S01E03.mkv", "Show S01E01E02.mkv"},
+			want:     []string{"Lost S01E01-E02.mkv", "Lost S01E03.mkv"},
+		},
+		{
+			name:     "numbers in title",
+			match:    "nxnn",
+			episodes: []string{"9-1-1 1x05.mkv", "9-1-1 1x04.mkv"},
+			preserve: true,
+			want:     []string{"Lost S01E04.mkv", "Lost S01E05.mkv"},
+		},
+		{
+			name:     "episode word",
+			match:    "episode",
+			episodes: []string{"Show Episode 10 1080p.mkv", "Show Episode 9 720p.mkv", "Show Ep08.mkv"},
+			want:     []string{"Lost S01E01.mkv", "Lost S01E02.mkv", "Lost S01E03.mkv"},
+		},
+		{
+			name:     "parts",
+			match:    `ep(?P<episode>\d+)(?:-pt(?P<part>\d))?`,
+			episodes: []string{"ep05-pt2.mkv", "ep05-pt1.mkv", "ep04.mkv", "ep06.mkv"},
+			want:     []string{"Lost S01E01.mkv", "Lost S01E02-part1.mkv", "Lost S01E02-part2.mkv", "Lost S01E03.mkv"},
+		},
+		{
+			name:     "preserved parts",
+			match:    `ep(?P<episode>\d+)(?:-pt(?P<part>\d))?`,
+			episodes: []string{"ep05-pt2.mkv", "ep05-pt1.mkv", "ep04.mkv"},
+			preserve: true,
+			want:     []string{"Lost S01E04.mkv", "Lost S01E05-part1.mkv", "Lost S01E05-part2.mkv"},
+		},
+		{
+			name:     "duplicate parts",
+			match:    `ep(?P<episode>\d+)(?:-pt(?P<part>\d))?`,
+			episodes: []string{"ep05-pt1.mkv", "ep5-pt1.mkv"},
+			preserve: true,
+			wantErr:  true,
+		},
+		{
+			name:     "season",
+			match:    `s(?P<season>\d+)e(?P<episode>\d+)`,
+			episodes: []string{"s1e2.mkv", "s01e1.mkv"},
+			want:     []string{"Lost S01E01.mkv", "Lost S01E02.mkv"},
+		},
+		{
+			name:     "other season",
+			match:    `s(?P<season>\d+)e(?P<episode>\d+)`,
+			episodes: []string{"s2e1.mkv", "s1e2.mkv"},
+			wantErr:  true,
+		},
+		{
+			name:     "no match",
+			match:    "sxxeyy",
+			episodes: []string{"ep1.mkv"},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			dir, showDir := setupShow(t, "Lost (2004) [tvdbid-73739]")
+			m, err := media.ParseMatcher(tt.match)
+			if err != nil {
+				t.Fatal(err)
+			}
+			eps := setupFiles(t, dir, tt.episodes...)
+			s := media.Season{N: "1", ShowDir: showDir, Episodes: eps, Match: m, Preserve: tt.preserve}
+			p, err := media.PlanSeason(s, media.Options{})
+			checkPlan(t, fmt.Sprintf("PlanSeason(%v)", s), p, err, tt.want, tt.wantErr)
+		})
+	}
+}
@@ -5,12 +5,14 @@
 package media_test
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
 
-	"github.com/matthewdargan/epify/internal/media"
+	"github.com/matthewdargan/epify/media"
 )
 
 func TestMkShow(t *testing.T) {
@@ -18,6 +20,7 @@ func TestMkShow(t *testing.T) {
 	tests := []struct {
 		name    string
 		s       media.Show
+		names   media.NameProfile
 		wantErr bool
 		path    string
 	}{
@@ -41,6 +44,48 @@ func TestMkShow(t *testing.T) {
 			s:    media.Show{Name: "The Office", Year: "2005", ID: "73244"},
 			path: "The Office (2005) [tvdbid-73244]",
 		},
+		{
+			name: "provider IDs",
+			s:    media.Show{Name: "Severance", Year: "2022", TMDBID: "95396", IMDBID: "tt11280740"},
+			path: "Severance (2022) [imdbid-tt11280740] [tmdbid-95396]",
+		},
+		{
+			name: "default and provider IDs",
+			s:    media.Show{Name: "Breaking Bad", Year: "2008", ID: "81189", IMDBID: "tt0903747"},
+			path: "Breaking Bad (2008) [imdbid-tt0903747] [tvdbid-81189]",
+		},
+		{
+			name:    "invalid imdbid",
+			s:       media.Show{Name: "Breaking Bad", Year: "2008", IMDBID: "0903747"},
+			wantErr: true,
+		},
+		{
+			name:    "missing id",
+			s:       media.Show{Name: "Breaking Bad", Year: "2008"},
+			wantErr: true,
+		},
+		{
+			name: "missing year",
+			s:    media.Show{Name: "Severance", ID: "371980"},
+			path: "Severance [tvdbid-371980]",
+		},
+		{
+			name: "slash in name",
+			s:    media.Show{Name: "Face/Off", Year: "1997", ID: "754"},
+			path: "Face-Off (1997) [tvdbid-754]",
+		},
+		{
+			name:  "windows name",
+			s:     media.Show{Name: "Mission: Impossible", Year: "1966", ID: "71448"},
+			names: media.Windows,
+			path:  "Mission - Impossible (1966) [tvdbid-71448]",
+		},
+		{
+			name:    "name of illegal characters",
+			s:       media.Show{Name: "???", Year: "2005", ID: "73244"},
+			names:   media.SMB,
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -51,7 +96,7 @@ func TestMkShow(t *testing.T) {
 			}
 			defer os.RemoveAll(dir)
 			tt.s.Dir = dir
-			err = media.MkShow(tt.s)
+			_, err = media.MkShow(context.Background(), tt.s, media.Options{Names: tt.names})
 			if (err != nil) != tt.wantErr {
 				t.Errorf("MkShow(%v) error = %v", tt.s, err)
 			}
@@ -120,6 +165,13 @@ func TestAddMovie(t *testing.T) {
 			cMovie: true,
 			path:   "Braveheart (2005) [tmdbid-197].mkv",
 		},
+		{
+			name:   "imdbid movie",
+			m:      media.Movie{Show: media.Show{Name: "Braveheart", Year: "1995", ID: "197", IMDBID: "tt0112573"}, File: "braveheart.mkv"},
+			cDir:   true,
+			cMovie: true,
+			path:   "Braveheart (1995) [imdbid-tt0112573] [tmdbid-197].mkv",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -147,7 +199,7 @@ func TestAddMovie(t *testing.T) {
 				defer os.RemoveAll(dir)
 				tt.m.File = setupFiles(t, dir, tt.m.File)[0]
 			}
-			err := media.AddMovie(tt.m)
+			_, err := media.AddMovie(context.Background(), tt.m, media.Options{})
 			if (err != nil) != tt.wantErr {
 				t.Errorf("AddMovie(%v) error = %v", tt.m, err)
 			}
@@ -192,10 +244,16 @@ func TestMkSeason(t *testing.T) {
 			cDir:    true,
 		},
 		{
-			name:    "directory missing year",
-			s:       media.Season{N: "3", ShowDir: "The Office [tvdbid-73244]"},
-			wantErr: true,
-			cDir:    true,
+			name:      "directory missing year",
+			s:         media.Season{N: "3", ShowDir: "Severance [tvdbid-371980]", Episodes: []string{"ep1.mkv"}},
+			cDir:      true,
+			cEpisodes: true,
+		},
+		{
+			name:      "parentheses in name",
+			s:         media.Season{N: "1", ShowDir: "Shameless (US) (2011) [tvdbid-161511]", Episodes: []string{"ep1.mkv"}},
+			cDir:      true,
+			cEpisodes: true,
 		},
 		{
 			name:    "directory missing space before year",
@@ -236,6 +294,13 @@ func TestMkSeason(t *testing.T) {
 			cDir:      true,
 			cEpisodes: true,
 		},
+		{
+			name:      "match index 1001 out of range",
+			s:         media.Season{N: "1", ShowDir: "Naruto Shippuden (2007) [tvdbid-79824]", Episodes: []string{"ep01.mkv"}, MatchIndex: 1001},
+			wantErr:   true,
+			cDir:      true,
+			cEpisodes: true,
+		},
 		{
 			name:      "match index 1 out of range",
 			s:         media.Season{N: "3", ShowDir: "Naruto Shippuden (2007) [tvdbid-79824]", Episodes: []string{"ep1.mkv"}, MatchIndex: 1},
@@ -322,7 +387,7 @@ func TestMkSeason(t *testing.T) {
 				defer os.RemoveAll(dir)
 				tt.s.Episodes = setupFiles(t, dir, tt.s.Episodes...)
 			}
-			err := media.MkSeason(tt.s)
+			_, err := media.MkSeason(context.Background(), tt.s, media.Options{})
 			if (err != nil) != tt.wantErr {
 				t.Errorf("MkSeason(%v) error = %v", tt.s, err)
 			}
@@ -389,11 +454,11 @@ func TestAddEpisodes(t *testing.T) {
 			showDir: "(2011) [tvdbid-121361]",
 		},
 		{
-			name:    "show directory missing year",
-			a:       media.Addition{SeasonDir: "Season 03"},
-			wantErr: true,
-			cDir:    true,
-			showDir: "Game of Thrones [tvdbid-121361]",
+			name:      "show directory missing year",
+			a:         media.Addition{SeasonDir: "Season 03", Episodes: []string{"ep1.mkv"}},
+			cDir:      true,
+			cEpisodes: true,
+			showDir:   "Game of Thrones [tvdbid-121361]",
 		},
 		{
 			name:    "show directory missing space before year",
@@ -457,36 +522,32 @@ func TestAddEpisodes(t *testing.T) {
 			showDir:   "One-Punch Man (2015) [tvdbid-293088]",
 		},
 		{
-			name:         "previous episode missing E",
+			name:         "previous episode missing E ignored",
 			a:            media.Addition{SeasonDir: "Season 10", Episodes: []string{"ep1.mkv"}},
-			wantErr:      true,
 			cDir:         true,
 			cEpisodes:    true,
 			showDir:      "Fullmetal Alchemist (2003) [tvdbid-75579]",
 			prevEpisodes: []string{"Fullmetal Alchemist S1001.mkv"},
 		},
 		{
-			name:         "previous episode missing period",
+			name:         "previous episode directory",
 			a:            media.Addition{SeasonDir: "Season 10", Episodes: []string{"ep1.mkv"}},
-			wantErr:      true,
 			cDir:         true,
 			cEpisodes:    true,
 			showDir:      "Fist of the North Star (1984) [tvdbid-79156]",
 			prevEpisodes: []string{"Fist of the North Star S10E01mkv"},
 		},
 		{
-			name:         "previous episode malformed",
+			name:         "previous file not video",
 			a:            media.Addition{SeasonDir: "Season 10", Episodes: []string{"ep1.mkv"}},
-			wantErr:      true,
 			cDir:         true,
 			cEpisodes:    true,
 			showDir:      "Berserk (1997) [tvdbid-73752]",
 			prevEpisodes: []string{"Berserk S10.01Emkv"},
 		},
 		{
-			name:         "previous episode invalid number",
+			name:         "previous episode invalid number ignored",
 			a:            media.Addition{SeasonDir: "Season 10", Episodes: []string{"ep1.mkv"}},
-			wantErr:      true,
 			cDir:         true,
 			cEpisodes:    true,
 			showDir:      "Vinland Saga (2019) [tvdbid-359274]",
@@ -580,7 +641,7 @@ func TestAddEpisodes(t *testing.T) {
 				defer os.RemoveAll(dir)
 				tt.a.Episodes = setupFiles(t, dir, tt.a.Episodes...)
 			}
-			err := media.AddEpisodes(tt.a)
+			_, err := media.AddEpisodes(context.Background(), tt.a, media.Options{})
 			if (err != nil) != tt.wantErr {
 				t.Errorf("AddEpisodes(%v) error = %v", tt.a, err)
 			}
@@ -602,6 +663,209 @@ func TestAddEpisodes(t *testing.T) {
 	}
 }
 
+func TestPreserve(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		episodes []string
+		prev     []string
+		want     []string
+		wantErr  bool
+	}{
+		{
+			name:     "partial season",
+			episodes: []string{"ep05.mkv", "ep03.mkv"},
+			want:     []string{"Baccano! S01E03.mkv", "Baccano! S01E05.mkv"},
+		},
+		{
+			name:     "single episode",
+			episodes: []string{"ep7.mkv"},
+			prev:     []string{"Baccano! S01E01.mkv", "Baccano! S01E09.mkv"},
+			want:     []string{"Baccano! S01E07.mkv"},
+		},
+		{
+			name:     "duplicate episode",
+			episodes: []string{"ep3.mkv", "ep03.mp4"},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			dir, seasonDir := setupShow(t, "Baccano! (2007) [tvdbid-80834]", "Season 01")
+			setupFiles(t, seasonDir, tt.prev...)
+			a := media.Addition{SeasonDir: seasonDir, Episodes: setupFiles(t, dir, tt.episodes...), Preserve: true}
+			p, err := media.PlanAddition(a, media.Options{})
+			checkPlan(t, fmt.Sprintf("PlanAddition(%v)", a), p, err, tt.want, tt.wantErr)
+		})
+	}
+}
+
+func TestNextEpisode(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		episodes []string
+		prev     []string
+		fillGaps bool
+		want     []string
+		wantErr  bool
+	}{
+		{
+			name:     "ignore non-episodes",
+			episodes: []string{"ep1.mkv"},
+			prev: []string{
+				"Lost S01E01.mkv", "Lost S01E02.mkv", "Lost S01E02.en.srt", "Lost S01E02.nfo",
+				"season.jpg", "Extras", "Lost S01E01.mkv.part",
+			},
+			want: []string{"Lost S01E03.mkv"},
+		},
+		{
+			name:     "highest episode",
+			episodes: []string{"ep1.mkv"},
+			prev:     []string{"Lost S01E09.mkv", "Lost S01E10.mkv", "Lost S01E02.mkv"},
+			want:     []string{"Lost S01E11.mkv"},
+		},
+		{
+			name:     "multiple episode file",
+			episodes: []string{"ep1.mkv"},
+			prev:     []string{"Lost S01E01.mkv", "Lost S01E02-E03.mkv"},
+			want:     []string{"Lost S01E04.mkv"},
+		},
+		{
+			name:     "fill gaps",
+			episodes: []string{"ep1.mkv", "ep2.mkv", "ep3-4.mkv", "ep5.mkv"},
+			prev:     []string{"Lost S01E01.mkv", "Lost S01E03.mkv", "Lost S01E06.mkv"},
+			fillGaps: true,
+			want:     []string{"Lost S01E02.mkv", "Lost S01E04.mkv", "Lost S01E07-E08.mkv", "Lost S01E09.mkv"},
+		},
+		{
+			name:     "fill gaps in empty season",
+			episodes: []string{"ep1.mkv", "ep2.mkv"},
+			fillGaps: true,
+			want:     []string{"Lost S01E01.mkv", "Lost S01E02.mkv"},
+		},
+		{
+			name:     "ignore non-episode videos",
+			episodes: []string{"ep1.mkv"},
+			prev:     []string{"Lost S01E01.mkv", "episode 2.mkv", "sample.mkv"},
+			want:     []string{"Lost S01E02.mkv"},
+		},
+		{
+			name:     "ignore other seasons",
+			episodes: []string{"ep1.mkv"},
+			prev:     []string{"Lost S01E01.mkv", "Lost S02E10.mkv"},
+			want:     []string{"Lost S01E02.mkv"},
+		},
+		{
+			name:     "titled episode",
+			episodes: []string{"ep1.mkv"},
+			prev:     []string{"Lost S01E01 - Pilot.mkv", "Lost S01E02 - Pilot (2).mkv"},
+			want:     []string{"Lost S01E03.mkv"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			dir, seasonDir := setupShow(t, "Lost (2004) [tvdbid-73739]", "Season 01")
+			setupFiles(t, seasonDir, tt.prev...)
+			a := media.Addition{SeasonDir: seasonDir, Episodes: setupFiles(t, dir, tt.episodes...), FillGaps: tt.fillGaps}
+			p, err := media.PlanAddition(a, media.Options{})
+			checkPlan(t, fmt.Sprintf("PlanAddition(%v)", a), p, err, tt.want, tt.wantErr)
+		})
+	}
+}
+
+func TestMultiEpisode(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name       string
+		episodes   []string
+		matchIndex int
+		preserve   bool
+		want       []string
+	}{
+		{
+			name:     "hyphenated range",
+			episodes: []string{"ep03.mkv", "ep01-02.mkv", "ep04.mkv"},
+			want:     []string{"Lost S01E01-E02.mkv", "Lost S01E03.mkv", "Lost S01E04.mkv"},
+		},
+		{
+			name:       "consecutive E numbers",
+			episodes:   []string{"S01E03.mkv", "S01E01E02.mkv"},
+			matchIndex: 1,
+			want:       []string{"Lost S01E01-E02.mkv", "Lost S01E03.mkv"},
+		},
+		{
+			name:       "three episodes",
+			episodes:   []string{"S01E23-E24-E25.mkv", "S01E22.mkv"},
+			matchIndex: 1,
+			preserve:   true,
+			want:       []string{"Lost S01E22.mkv", "Lost S01E23-E25.mkv"},
+		},
+		{
+			name:     "season before episode",
+			episodes: []string{"S01E05.mkv", "S02E01.mkv"},
+			want:     []string{"Lost S01E01.mkv", "Lost S01E02.mkv"},
+		},
+		{
+			name:     "resolution after episode",
+			episodes: []string{"ep01-720p.mkv", "ep02-1080p.mkv"},
+			want:     []string{"Lost S01E01.mkv", "Lost S01E02.mkv"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			dir, showDir := setupShow(t, "Lost (2004) [tvdbid-73739]")
+			s := media.Season{
+				N:          "1",
+				ShowDir:    showDir,
+				Episodes:   setupFiles(t, dir, tt.episodes...),
+				MatchIndex: tt.matchIndex,
+				Preserve:   tt.preserve,
+			}
+			p, err := media.PlanSeason(s, media.Options{})
+			checkPlan(t, fmt.Sprintf("PlanSeason(%v)", s), p, err, tt.want, false)
+		})
+	}
+}
+
+func TestSpecials(t *testing.T) {
+	t.Parallel()
+	dir, err := os.MkdirTemp("", "specials")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	showDir := filepath.Join(dir, "Doctor Who (2005) [tvdbid-78804]")
+	if err = os.Mkdir(showDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	s := media.Season{N: "1", Specials: true, ShowDir: showDir, Episodes: setupFiles(t, dir, "special1.mkv")}
+	if _, err = media.MkSeason(context.Background(), s, media.Options{}); err == nil {
+		t.Errorf("MkSeason(%v) error = nil, want specials error", s)
+	}
+	s.N = "0"
+	if _, err = media.MkSeason(context.Background(), s, media.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	seasonDir := filepath.Join(showDir, "Specials")
+	a := media.Addition{SeasonDir: seasonDir, Episodes: setupFiles(t, dir, "special2.mkv")}
+	if _, err = media.AddEpisodes(context.Background(), a, media.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, ep := range []string{"Doctor Who S00E01.mkv", "Doctor Who S00E02.mkv"} {
+		if _, err := os.Stat(filepath.Join(seasonDir, ep)); err != nil {
+			t.Errorf("AddEpisodes(%v) = %v, want %v", a, err, ep)
+		}
+	}
+	s = media.Season{N: "00", ShowDir: showDir, Episodes: setupFiles(t, dir, "special3.mkv")}
+	if _, err = media.MkSeason(context.Background(), s, media.Options{}); err == nil {
+		t.Errorf("MkSeason(%v) error = nil, want existing specials error", s)
+	}
+}
+
 func setupFiles(t *testing.T, dir string, fs ...string) []string {
 	t.Helper()
 	ps := make([]string, len(fs))
@@ -622,3 +886,90 @@ func setupFiles(t *testing.T, dir string, fs ...string) []string {
 	}
 	return ps
 }
+
+// setupShow creates the directory elem names in a new temporary directory and
+// returns both.
+func setupShow(t *testing.T, elem ...string) (dir, path string) {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "show")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path = filepath.Join(append([]string{dir}, elem...)...)
+	if err = os.MkdirAll(path, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return dir, path
+}
+
+// checkPlan checks that call returned an error if wantErr is set, and
+// otherwise a plan p that places the files named want, in order.
+func checkPlan(t *testing.T, call string, p media.Plan, err error, want []string, wantErr bool) {
+	t.Helper()
+	if (err != nil) != wantErr {
+		t.Fatalf("%s error = %v", call, err)
+	}
+	if wantErr {
+		return
+	}
+	if got := plannedNames(p); !slices.Equal(got, want) {
+		t.Errorf("%s = %v, want %v", call, got, want)
+	}
+}
+
+// plannedNames returns the base names of the files p places.
+func plannedNames(p media.Plan) []string {
+	var names []string
+	for _, o := range p.Ops {
+		if o.Kind.IsPlacement() {
+			names = append(names, filepath.Base(o.Dst))
+		}
+	}
+	return names
+}
+
+func TestShowDirName(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		showDir string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:    "parentheses in name",
+			showDir: "Shameless (US) (2011) [tvdbid-161511]",
+			want:    []string{"Shameless (US) S01E01.mkv"},
+		},
+		{
+			name:    "missing year",
+			showDir: "Severance [imdbid-tt11280740] [tmdbid-95396]",
+			want:    []string{"Severance S01E01.mkv"},
+		},
+		{
+			name:    "name only",
+			showDir: "Shameless (UK)",
+			want:    []string{"Shameless (UK) S01E01.mkv"},
+		},
+		{
+			name:    "invalid id",
+			showDir: "The Office (2005) [tvdbid-seventy]",
+			wantErr: true,
+		},
+		{
+			name:    "duplicate id",
+			showDir: "The Office (2005) [tvdbid-73244] [tvdbid-73244]",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			dir, showDir := setupShow(t, tt.showDir)
+			s := media.Season{N: "1", ShowDir: showDir, Episodes: setupFiles(t, dir, "ep1.mkv")}
+			p, err := media.PlanSeason(s, media.Options{})
+			checkPlan(t, fmt.Sprintf("PlanSeason(%v)", s), p, err, tt.want, tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2024 Matthew P. Dargan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package media_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/matthewdargan/epify/media"
+)
+
+func TestTVDB(t *testing.T) {
+	t.Parallel()
+	var logins int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			var body struct {
+				APIKey string `json:"apikey"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.APIKey != "key" {
+				http.Error(w, `{"message":"bad key"}`, http.StatusUnauthorized)
+				return
+			}
+			logins++
+			json.NewEncoder(w).Encode(map[string]any{"data": map[string]string{"token": "token"}})
+		case "/search":
+			// The first token expires after one search.
+			if r.Header.Get("Authorization") != "Bearer token" || logins == 1 && r.URL.Query().Get("type") == "movie" {
+				http.Error(w, `{"message":"expired"}`, http.StatusUnauthorized)
+				return
+			}
+			data := []map[string]string{
+				{"tvdb_id": "71256", "name": "The Office (UK)", "year": "2001"},
+				{"tvdb_id": "73244", "name": "The Office (US)", "year": "2005"},
+			}
+			if r.URL.Query().Get("type") == "movie" {
+				data = []map[string]string{{"tvdb_id": "1299", "name": "Office Space", "year": "1999"}}
+			}
+			json.NewEncoder(w).Encode(map[string]any{"data": data})
+		case "/series/73244/episodes/default":
+			resp := map[string]any{
+				"data": map[string]any{"episodes": []map[string]any{
+					{"seasonNumber": 1, "number": 2, "name": "Diversity Day"},
+					{"seasonNumber": 1, "number": 1, "name": "Pilot", "aired": "2005-03-24"},
+				}},
+				"links": map[string]any{"next": "page=1"},
+			}
+			if r.URL.Query().Get("page") == "1" {
+				resp = map[string]any{
+					"data":  map[string]any{"episodes": []map[string]any{{"seasonNumber": 0, "number": 1}}},
+					"links": map[string]any{"next": nil},
+				}
+			}
+			json.NewEncoder(w).Encode(resp)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+	p := &media.TVDB{BaseURL: srv.URL, Key: "key"}
+	s, err := media.LookupShow(ctx, p, media.Show{Name: "The Office", Year: "2005", Dir: "shows"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := media.Show{Name: "The Office", Year: "2005", ID: "73244", Dir: "shows"}
+	if s != want {
+		t.Errorf("LookupShow() = %+v, want %+v", s, want)
+	}
+	m, err := media.LookupMovie(ctx, p, media.Movie{Show: media.Show{Name: "Office Space"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Year != "1999" || m.ID != "1299" {
+		t.Errorf("LookupMovie() = %+v, want year 1999 and ID 1299", m)
+	}
+	if logins != 2 {
+		t.Errorf("logins = %d, want 2", logins)
+	}
+	es, err := p.Episodes(ctx, "73244")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantEps := []media.Episode{
+		{Season: 0, Number: 1},
+		{Season: 1, Number: 1, Name: "Pilot", Aired: "2005-03-24"},
+		{Season: 1, Number: 2, Name: "Diversity Day"},
+	}
+	if !slices.Equal(es, wantEps) {
+		t.Errorf("Episodes() = %v, want %v", es, wantEps)
+	}
+	if _, err = media.LookupShow(ctx, p, media.Show{Name: "The Office", Year: "2020"}); err == nil {
+		t.Error("LookupShow() error = nil, want no results error")
+	}
+	p = &media.TVDB{BaseURL: srv.URL, Key: "wrong"}
+	if _, err = p.SearchShows(ctx, "The Office"); err == nil {
+		t.Error("SearchShows() error = nil, want unauthorized error")
+	}
+}
+
+func TestTMDB(t *testing.T) {
+	t.Parallel()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("api_key") != "key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"status_message":"Invalid API key"}`))
+			return
+		}
+		var results []map[string]any
+		switch r.URL.Path {
+		case "/search/tv":
+			results = []map[string]any{
+				{"id": 2316, "name": "The Office", "first_air_date": "2005-03-24"},
+			}
+		case "/search/movie":
+			results = []map[string]any{
+				{"id": 11, "title": "Star Wars: The Clone Wars", "release_date": "2008-08-05"},
+				{"id": 1891, "title": "Star Wars", "release_date": "1977-05-25"},
+			}
+		case "/tv/2316":
+			json.NewEncoder(w).Encode(map[string]any{"seasons": []map[string]any{{"season_number": 1}, {"season_number": 2}}})
+			return
+		case "/tv/2316/season/1", "/tv/2316/season/2":
+			n := int(r.URL.Path[len(r.URL.Path)-1] - '0')
+			json.NewEncoder(w).Encode(map[string]any{"episodes": []map[string]any{
+				{"season_number": n, "episode_number": 1, "name": "Premiere", "air_date": "2005-03-24"},
+			}})
+			return
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"results": results})
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+	p := &media.TMDB{BaseURL: srv.URL, Key: "key"}
+	s, err := media.LookupShow(ctx, p, media.Show{Name: "the office"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Name != "the office" || s.Year != "2005" || s.ID != "2316" {
+		t.Errorf("LookupShow() = %+v, want year 2005 and ID 2316", s)
+	}
+	m, err := media.LookupMovie(ctx, p, media.Movie{Show: media.Show{Name: "Star Wars"}, File: "sw.mkv"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := media.Movie{Show: media.Show{Name: "Star Wars", Year: "1977", ID: "1891"}, File: "sw.mkv"}
+	if m != want {
+		t.Errorf("LookupMovie() = %+v, want %+v", m, want)
+	}
+	es, err := p.Episodes(ctx, "2316")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantEps := []media.Episode{
+		{Season: 1, Number: 1, Name: "Premiere", Aired: "2005-03-24"},
+		{Season: 2, Number: 1, Name: "Premiere", Aired: "2005-03-24"},
+	}
+	if !slices.Equal(es, wantEps) {
+		t.Errorf("Episodes() = %v, want %v", es, wantEps)
+	}
+	p.Key = "wrong"
+	_, err = p.SearchMovies(ctx, "Star Wars")
+	if e, ok := err.(*media.APIError); !ok || e.StatusCode != http.StatusUnauthorized || e.Message != "Invalid API key" {
+		t.Errorf("SearchMovies() error = %v, want unauthorized APIError", err)
+	}
+}
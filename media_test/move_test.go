@@ -0,0 +1,72 @@
+// Copyright 2024 Matthew P. Dargan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package media_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/matthewdargan/epify/media"
+)
+
+// TestApplyCrossDevice renames a file from the temporary directory to
+// /dev/shm, which is a separate filesystem on most Linux systems.
+func TestApplyCrossDevice(t *testing.T) {
+	t.Parallel()
+	if _, err := os.Stat("/dev/shm"); err != nil {
+		t.Skip("/dev/shm unavailable")
+	}
+	src, err := os.MkdirTemp("", "download")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(src)
+	dst, err := os.MkdirTemp("/dev/shm", "media")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dst)
+	data := bytes.Repeat([]byte("epify"), 1<<18)
+	movie := filepath.Join(src, "braveheart.mkv")
+	if err = os.WriteFile(movie, data, 0o640); err != nil {
+		t.Fatal(err)
+	}
+	m := media.Movie{Show: media.Show{Name: "Braveheart", Year: "1995", ID: "197", Dir: dst}, File: movie}
+	p, err := media.PlanMovie(m, media.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = p.Apply(context.Background(), media.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(movie); !os.IsNotExist(err) {
+		t.Errorf("Apply() left source %v", movie)
+	}
+	target := filepath.Join(dst, "Braveheart (1995) [tmdbid-197].mkv")
+	got, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("Apply() copied %d bytes, want %d", len(got), len(data))
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o640 {
+		t.Errorf("Apply() mode = %v, want %v", info.Mode().Perm(), os.FileMode(0o640))
+	}
+	ents, err := os.ReadDir(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ents) != 1 {
+		t.Errorf("Apply() left %d files in %v, want 1", len(ents), dst)
+	}
+}
@@ -0,0 +1,254 @@
+// Copyright 2024 Matthew P. Dargan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package media_test
+
+import (
+	"testing"
+
+	"github.com/matthewdargan/epify/media"
+)
+
+func TestParseShowDir(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		want    media.Show
+		wantErr bool
+	}{
+		{
+			name: "The Office (2005) [tvdbid-73244]",
+			want: media.Show{Name: "The Office", Year: "2005", ID: "73244", TVDBID: "73244"},
+		},
+		{
+			name: "Shameless (US) (2011) [tvdbid-161511]",
+			want: media.Show{Name: "Shameless (US)", Year: "2011", ID: "161511", TVDBID: "161511"},
+		},
+		{
+			name: "Severance (2022) [imdbid-tt11280740] [tmdbid-95396]",
+			want: media.Show{Name: "Severance", Year: "2022", TMDBID: "95396", IMDBID: "tt11280740"},
+		},
+		{
+			name: "Severance [tvdbid-371980]",
+			want: media.Show{Name: "Severance", ID: "371980", TVDBID: "371980"},
+		},
+		{
+			name: "Shameless (UK)",
+			want: media.Show{Name: "Shameless (UK)"},
+		},
+		{
+			name:    "(2005) [tvdbid-73244]",
+			wantErr: true,
+		},
+		{
+			name:    "The Office(2005) [tvdbid-73244]",
+			wantErr: true,
+		},
+		{
+			name:    "The Office (2005) [imdbid-73244]",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := media.ParseShowDir(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseShowDir(%q) error = %v", tt.name, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseShowDir(%q) = %+v, want %+v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseIDs(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		ids     string
+		want    media.Show
+		wantErr bool
+	}{
+		{ids: "73244", want: media.Show{ID: "73244"}},
+		{ids: "tvdbid-73244", want: media.Show{TVDBID: "73244"}},
+		{ids: "imdbid-tt0112573,tmdbid-197", want: media.Show{IMDBID: "tt0112573", TMDBID: "197"}},
+		{ids: "imdbid-tt1,imdbid-tt2", wantErr: true},
+		{ids: "imdbid-197", wantErr: true},
+		{ids: "tvdb-73244", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ids, func(t *testing.T) {
+			t.Parallel()
+			got, err := media.ParseIDs(tt.ids)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseIDs(%q) error = %v", tt.ids, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseIDs(%q) = %+v, want %+v", tt.ids, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSeasonDir(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		want    int
+		wantErr bool
+	}{
+		{name: "Season 01", want: 1},
+		{name: "Season 2", want: 2},
+		{name: "Season 100", want: 100},
+		{name: "Specials", want: 0},
+		{name: "Season three", wantErr: true},
+		{name: "Extras", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := media.ParseSeasonDir(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSeasonDir(%q) error = %v", tt.name, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseSeasonDir(%q) = %d, want %d", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseEpisodeFile(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		want    media.EpisodeFile
+		wantErr bool
+	}{
+		{
+			name: "The Office S01E01.mkv",
+			want: media.EpisodeFile{Show: "The Office", Season: 1, Episode: 1, Last: 1, Suffix: ".mkv"},
+		},
+		{
+			name: "Mr. Robot S02E03-E04.mkv",
+			want: media.EpisodeFile{Show: "Mr. Robot", Season: 2, Episode: 3, Last: 4, Suffix: ".mkv"},
+		},
+		{
+			name: "Shameless (US) S11E12.en.forced.srt",
+			want: media.EpisodeFile{Show: "Shameless (US)", Season: 11, Episode: 12, Last: 12, Suffix: ".en.forced.srt"},
+		},
+		{
+			name: "One Piece S01E1071.mkv",
+			want: media.EpisodeFile{Show: "One Piece", Season: 1, Episode: 1071, Last: 1071, Suffix: ".mkv"},
+		},
+		{
+			name: "Lost S02E09 - What Kate Did.mkv",
+			want: media.EpisodeFile{Show: "Lost", Season: 2, Episode: 9, Last: 9, Title: "What Kate Did", Suffix: ".mkv"},
+		},
+		{
+			name: "Lost S02E14 - One of Them. Part 1.mkv",
+			want: media.EpisodeFile{Show: "Lost", Season: 2, Episode: 14, Last: 14, Title: "One of Them. Part 1", Suffix: ".mkv"},
+		},
+		{
+			name: "Lost S01E01 - Pilot.en.srt",
+			want: media.EpisodeFile{Show: "Lost", Season: 1, Episode: 1, Last: 1, Title: "Pilot", Suffix: ".en.srt"},
+		},
+		{
+			name: "Mr. Robot S01E01 - eps1.0_hellofriend.mov.mkv",
+			want: media.EpisodeFile{Show: "Mr. Robot", Season: 1, Episode: 1, Last: 1, Title: "eps1.0_hellofriend.mov", Suffix: ".mkv"},
+		},
+		{
+			name: "Lost S01E01 - Pilot.pt-BR.forced.SRT",
+			want: media.EpisodeFile{Show: "Lost", Season: 1, Episode: 1, Last: 1, Title: "Pilot", Suffix: ".pt-BR.forced.SRT"},
+		},
+		{
+			name: "Lost S01E01 (1).en.srt",
+			want: media.EpisodeFile{Show: "Lost", Season: 1, Episode: 1, Last: 1, Dup: 1, Suffix: ".en.srt"},
+		},
+		{
+			name: "Lost S01E01-E02 - Pilot (2).mkv",
+			want: media.EpisodeFile{Show: "Lost", Season: 1, Episode: 1, Last: 2, Title: "Pilot", Dup: 2, Suffix: ".mkv"},
+		},
+		{
+			name: "Lost S01E05-part2 - Exodus.mkv",
+			want: media.EpisodeFile{Show: "Lost", Season: 1, Episode: 5, Last: 5, Part: 2, Title: "Exodus", Suffix: ".mkv"},
+		},
+		{
+			name: "S00E01",
+			want: media.EpisodeFile{Episode: 1, Last: 1},
+		},
+		{
+			name:    "The Office S01E05-E05.mkv",
+			wantErr: true,
+		},
+		{
+			name:    "The Office S01E05.txt",
+			wantErr: true,
+		},
+		{
+			name:    "The Office 1x05.mkv",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := media.ParseEpisodeFile(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseEpisodeFile(%q) error = %v", tt.name, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseEpisodeFile(%q) = %+v, want %+v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMovieFile(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		want    media.Movie
+		wantErr bool
+	}{
+		{
+			name: "Braveheart (1995) [tmdbid-197].mkv",
+			want: media.Movie{
+				Show: media.Show{Name: "Braveheart", Year: "1995", ID: "197", TMDBID: "197"},
+				File: "Braveheart (1995) [tmdbid-197].mkv",
+			},
+		},
+		{
+			name: "Braveheart (1995) [imdbid-tt0112573] [tmdbid-197].mkv",
+			want: media.Movie{
+				Show: media.Show{Name: "Braveheart", Year: "1995", ID: "197", TMDBID: "197", IMDBID: "tt0112573"},
+				File: "Braveheart (1995) [imdbid-tt0112573] [tmdbid-197].mkv",
+			},
+		},
+		{
+			name: "Mission - Impossible (1996).mp4",
+			want: media.Movie{
+				Show: media.Show{Name: "Mission - Impossible", Year: "1996"},
+				File: "Mission - Impossible (1996).mp4",
+			},
+		},
+		{
+			name:    "(1995) [tmdbid-197].mkv",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := media.ParseMovieFile(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseMovieFile(%q) error = %v", tt.name, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseMovieFile(%q) = %+v, want %+v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,329 @@
+// Copyright 2024 Matthew P. Dargan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package media_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/matthewdargan/epify/media"
+)
+
+func TestPlanShow(t *testing.T) {
+	t.Parallel()
+	dir, err := os.MkdirTemp("", "show")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s := media.Show{Name: "The Office", Year: "2005", ID: "73244", Dir: filepath.Join(dir, "shows")}
+	p, err := media.PlanShow(s, media.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []media.Op{
+		{Kind: media.Mkdir, Dst: s.Dir},
+		{Kind: media.Mkdir, Dst: filepath.Join(s.Dir, "The Office (2005) [tvdbid-73244]")},
+	}
+	if !slices.Equal(p.Ops, want) {
+		t.Errorf("PlanShow(%v) = %v, want %v", s, p.Ops, want)
+	}
+	if _, err := os.Stat(s.Dir); !os.IsNotExist(err) {
+		t.Errorf("PlanShow(%v) created %v", s, s.Dir)
+	}
+	if _, err := p.Apply(context.Background(), media.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if p, err = media.PlanShow(s, media.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Ops) != 0 {
+		t.Errorf("PlanShow(%v) = %v, want no operations", s, p.Ops)
+	}
+}
+
+func TestPlanSeason(t *testing.T) {
+	t.Parallel()
+	showDir := filepath.Join(os.TempDir(), "Cowboy Bebop (1998) [tvdbid-76885]")
+	if err := os.MkdirAll(showDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(showDir)
+	dir, err := os.MkdirTemp("", "season")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	eps := setupFiles(t, dir, "ep10.mkv", "ep9.mkv")
+	s := media.Season{N: "1", ShowDir: showDir, Episodes: slices.Clone(eps)}
+	p, err := media.PlanSeason(s, media.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	seasonDir := filepath.Join(showDir, "Season 01")
+	want := []media.Op{
+		{Kind: media.Mkdir, Dst: seasonDir},
+		{Kind: media.Rename, Src: eps[1], Dst: filepath.Join(seasonDir, "Cowboy Bebop S01E01.mkv")},
+		{Kind: media.Rename, Src: eps[0], Dst: filepath.Join(seasonDir, "Cowboy Bebop S01E02.mkv")},
+	}
+	if !slices.Equal(p.Ops, want) {
+		t.Errorf("PlanSeason(%v) = %v, want %v", s, p.Ops, want)
+	}
+	if _, err := os.Stat(seasonDir); !os.IsNotExist(err) {
+		t.Errorf("PlanSeason(%v) created %v", s, seasonDir)
+	}
+	for _, e := range eps {
+		if _, err := os.Stat(e); err != nil {
+			t.Errorf("PlanSeason(%v) moved %v", s, e)
+		}
+	}
+}
+
+func TestPlanAddition(t *testing.T) {
+	t.Parallel()
+	seasonDir := filepath.Join(os.TempDir(), "Trigun (1998) [tvdbid-72104]", "Season 01")
+	if err := os.MkdirAll(seasonDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(filepath.Dir(seasonDir))
+	setupFiles(t, seasonDir, "Trigun S01E01.mkv")
+	dir, err := os.MkdirTemp("", "season")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	eps := setupFiles(t, dir, "ep2.mkv")
+	a := media.Addition{SeasonDir: seasonDir, Episodes: eps}
+	p, err := media.PlanAddition(a, media.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := media.Plan{Ops: []media.Op{
+		{Kind: media.Rename, Src: eps[0], Dst: filepath.Join(seasonDir, "Trigun S01E02.mkv")},
+	}}
+	if got, w := p.String(), want.String(); got != w {
+		t.Errorf("PlanAddition(%v) = %q, want %q", a, got, w)
+	}
+	if _, err := p.Apply(context.Background(), media.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(want.Ops[0].Dst); err != nil {
+		t.Errorf("Apply() = %v, want %v", err, want.Ops[0].Dst)
+	}
+}
+
+func TestPlacement(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		mode    media.OpKind
+		wantErr bool
+		symlink bool
+	}{
+		{name: "rename", mode: media.Rename},
+		{name: "link", mode: media.Link},
+		{name: "symlink", mode: media.Symlink, symlink: true},
+		{name: "reflink", mode: media.Reflink},
+		{name: "copy", mode: media.Copy},
+		{name: "mkdir", mode: media.Mkdir, wantErr: true},
+		{name: "remove", mode: media.Remove, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			dir, err := os.MkdirTemp("", "movie")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			m := media.Movie{
+				Show: media.Show{Name: "Akira", Year: "1988", ID: "149", Dir: dir},
+				File: setupFiles(t, dir, "akira.mkv")[0],
+			}
+			p, err := media.PlanMovie(m, media.Options{Mode: tt.mode})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("PlanMovie(%v) error = %v", m, err)
+			}
+			if tt.wantErr {
+				return
+			}
+			if _, err = p.Apply(context.Background(), media.Options{}); err != nil {
+				if tt.mode == media.Reflink {
+					t.Skipf("reflink unsupported: %v", err)
+				}
+				t.Fatal(err)
+			}
+			if _, err = os.Stat(m.File); (err == nil) == (tt.mode == media.Rename) {
+				t.Errorf("Apply() source exists = %v", err == nil)
+			}
+			info, err := os.Lstat(filepath.Join(dir, "Akira (1988) [tmdbid-149].mkv"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := info.Mode()&os.ModeSymlink != 0; got != tt.symlink {
+				t.Errorf("Apply() symlink = %v, want %v", got, tt.symlink)
+			}
+		})
+	}
+}
+
+func TestApplyValidate(t *testing.T) {
+	t.Parallel()
+	dir, err := os.MkdirTemp("", "validate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	eps := setupFiles(t, dir, "ep1.mkv", "ep2.mkv")
+	seasonDir := filepath.Join(dir, "Season 01")
+	p := media.Plan{Ops: []media.Op{
+		{Kind: media.Mkdir, Dst: seasonDir},
+		{Kind: media.Rename, Src: eps[0], Dst: filepath.Join(seasonDir, "Show S01E01.mkv")},
+		{Kind: media.Rename, Src: filepath.Join(dir, "missing.mkv"), Dst: filepath.Join(seasonDir, "Show S01E02.mkv")},
+		{Kind: media.Rename, Src: eps[1], Dst: filepath.Join(dir, "Season 02", "Show S02E01.mkv")},
+		{Kind: media.Rename, Src: eps[1], Dst: filepath.Join(seasonDir, "Show S01E01.mkv")},
+	}}
+	_, err = p.Apply(context.Background(), media.Options{})
+	if err == nil {
+		t.Fatalf("Apply(%v) error = nil, want validation errors", p)
+	}
+	if n := len(unwrapAll(err)); n != 3 {
+		t.Errorf("Apply(%v) error = %v, want 3 errors", p, err)
+	}
+	if _, err = os.Stat(seasonDir); !os.IsNotExist(err) {
+		t.Errorf("Apply(%v) created %q", p, seasonDir)
+	}
+}
+
+func TestApplyRollback(t *testing.T) {
+	t.Parallel()
+	dir, err := os.MkdirTemp("", "rollback")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	eps := setupFiles(t, dir, "ep1.mkv", "ep2.mkv", "ep3")
+	seasonDir := filepath.Join(dir, "Season 02")
+	existing := filepath.Join(dir, "Show S01E01.mkv")
+	writeFile(t, existing, "old")
+	p := media.Plan{Ops: []media.Op{
+		{Kind: media.Mkdir, Dst: seasonDir},
+		{Kind: media.Rename, Src: eps[0], Dst: filepath.Join(seasonDir, "Show S02E01.mkv")},
+		{Kind: media.Link, Src: eps[1], Dst: existing, Replace: true},
+		// Copying a directory fails after validation succeeds.
+		{Kind: media.Copy, Src: eps[2], Dst: filepath.Join(seasonDir, "Show S02E02.mkv")},
+	}}
+	if _, err = p.Apply(context.Background(), media.Options{}); err == nil {
+		t.Fatalf("Apply(%v) error = nil, want copy error", p)
+	}
+	for _, e := range eps {
+		if _, err = os.Stat(e); err != nil {
+			t.Errorf("Apply(%v) did not restore %q: %v", p, e, err)
+		}
+	}
+	if _, err = os.Stat(seasonDir); !os.IsNotExist(err) {
+		t.Errorf("Apply(%v) left %q", p, seasonDir)
+	}
+	if b, _ := os.ReadFile(existing); string(b) != "old" {
+		t.Errorf("Apply(%v) replaced %q with %q", p, existing, b)
+	}
+	ents, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ents) != 4 {
+		t.Errorf("Apply(%v) left %d files, want 4", p, len(ents))
+	}
+
+	// A removal that fails restores the files already removed.
+	p = media.Plan{Ops: []media.Op{
+		{Kind: media.Remove, Dst: existing},
+		{Kind: media.Remove, Dst: dir},
+	}}
+	if _, err = p.Apply(context.Background(), media.Options{}); err == nil {
+		t.Fatalf("Apply(%v) error = nil, want remove error", p)
+	}
+	if b, _ := os.ReadFile(existing); string(b) != "old" {
+		t.Errorf("Apply(%v) did not restore %q", p, existing)
+	}
+	p.Ops = p.Ops[:1]
+	if _, err = p.Apply(context.Background(), media.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if ents, _ = os.ReadDir(dir); len(ents) != 3 {
+		t.Errorf("Apply(%v) left %d files, want 3", p, len(ents))
+	}
+}
+
+func TestApplyRemoveDir(t *testing.T) {
+	t.Parallel()
+	dir, err := os.MkdirTemp("", "remove")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	eps := setupFiles(t, dir, "ep1.mkv")
+	seasonDir := filepath.Join(dir, "Season 01")
+	ep := filepath.Join(seasonDir, "Show S01E01.mkv")
+	p := media.Plan{Ops: []media.Op{
+		{Kind: media.Mkdir, Dst: seasonDir},
+		{Kind: media.Copy, Src: eps[0], Dst: ep},
+	}}
+	if _, err = p.Apply(context.Background(), media.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	// The file removed first is backed up outside the directory removed after.
+	p = media.Plan{Ops: []media.Op{
+		{Kind: media.Remove, Dst: ep},
+		{Kind: media.Remove, Dst: seasonDir},
+	}}
+	if _, err = p.Apply(context.Background(), media.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if ents, _ := os.ReadDir(dir); len(ents) != 1 {
+		t.Errorf("Apply(%v) left %d files, want 1", p, len(ents))
+	}
+}
+
+func TestApplyResult(t *testing.T) {
+	t.Parallel()
+	dir, err := os.MkdirTemp("", "result")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	eps := setupFiles(t, dir, "ep1.mkv", "ep2.mkv", "ep3.mkv")
+	seasonDir := filepath.Join(dir, "Season 01")
+	p := media.Plan{Ops: []media.Op{
+		{Kind: media.Mkdir, Dst: seasonDir},
+		{Kind: media.Rename, Src: eps[0], Dst: filepath.Join(seasonDir, "Show S01E01.mkv")},
+		{Kind: media.Copy, Src: eps[1], Dst: filepath.Join(seasonDir, "Show S01E02.mkv")},
+		{Kind: media.Remove, Dst: eps[2]},
+	}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = p.Apply(ctx, media.Options{}); err == nil {
+		t.Fatalf("Apply(%v) error = nil, want context canceled", p)
+	}
+	if _, err = os.Stat(seasonDir); !os.IsNotExist(err) {
+		t.Errorf("Apply(%v) created %q despite cancellation", p, seasonDir)
+	}
+	r, err := p.Apply(context.Background(), media.Options{Concurrency: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{seasonDir}; !slices.Equal(r.Created, want) {
+		t.Errorf("Apply(%v) created %v, want %v", p, r.Created, want)
+	}
+	if !slices.Equal(r.Placed, p.Ops[1:3]) {
+		t.Errorf("Apply(%v) placed %v, want %v", p, r.Placed, p.Ops[1:3])
+	}
+	if want := []string{eps[2]}; !slices.Equal(r.Removed, want) {
+		t.Errorf("Apply(%v) removed %v, want %v", p, r.Removed, want)
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2024 Matthew P. Dargan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package media_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/matthewdargan/epify/media"
+)
+
+func TestSanitize(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		profile media.NameProfile
+		in      string
+		want    string
+	}{
+		{
+			name:    "posix slash",
+			profile: media.POSIX,
+			in:      "Face/Off",
+			want:    "Face-Off",
+		},
+		{
+			name:    "posix colon",
+			profile: media.POSIX,
+			in:      "Mission: Impossible",
+			want:    "Mission: Impossible",
+		},
+		{
+			name:    "posix trailing dot",
+			profile: media.POSIX,
+			in:      " Scrubs. ",
+			want:    "Scrubs.",
+		},
+		{
+			name:    "windows colon",
+			profile: media.Windows,
+			in:      "Mission: Impossible",
+			want:    "Mission - Impossible",
+		},
+		{
+			name:    "windows time",
+			profile: media.Windows,
+			in:      "11:14",
+			want:    "11-14",
+		},
+		{
+			name:    "windows illegal characters",
+			profile: media.Windows,
+			in:      `What If...? <"Marvel"> *|\`,
+			want:    "What If... 'Marvel' -",
+		},
+		{
+			name:    "windows trailing dots and spaces",
+			profile: media.Windows,
+			in:      "What If... ",
+			want:    "What If",
+		},
+		{
+			name:    "windows control characters",
+			profile: media.Windows,
+			in:      "The\tOffice\x00",
+			want:    "TheOffice",
+		},
+		{
+			name:    "windows reserved name",
+			profile: media.Windows,
+			in:      "con",
+			want:    "con_",
+		},
+		{
+			name:    "windows reserved name with extension",
+			profile: media.SMB,
+			in:      "CON.mkv",
+			want:    "CON_.mkv",
+		},
+		{
+			name:    "smb colon",
+			profile: media.SMB,
+			in:      "Star Trek: Discovery",
+			want:    "Star Trek - Discovery",
+		},
+		{
+			name:    "posix length",
+			profile: media.POSIX,
+			in:      strings.Repeat("é", 200),
+			want:    strings.Repeat("é", 127),
+		},
+		{
+			name:    "smb length",
+			profile: media.SMB,
+			in:      strings.Repeat("é", 200),
+			want:    strings.Repeat("é", 127),
+		},
+		{
+			name:    "windows length",
+			profile: media.Windows,
+			in:      strings.Repeat("é", 300),
+			want:    strings.Repeat("é", 255),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := tt.profile.Sanitize(tt.in); got != tt.want {
+				t.Errorf("%v.Sanitize(%q) = %q, want %q", tt.profile, tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeEpisodes(t *testing.T) {
+	t.Parallel()
+	dir, err := os.MkdirTemp("", "sanitize")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	show := strings.Repeat("a", 233)
+	seasonDir := filepath.Join(dir, show+" (2005) [tvdbid-73244]", "Season 01")
+	if err = os.MkdirAll(seasonDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	eps := setupFiles(t, dir, "ep1.mkv", "ep1.en.forced.sdh.srt")
+	p, err := media.PlanAddition(media.Addition{SeasonDir: seasonDir, Episodes: eps}, media.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Repeat("a", 255-len(" S01E01.en.forced.sdh.srt")) + " S01E01"
+	for _, o := range p.Ops {
+		if got := filepath.Base(o.Dst); !strings.HasPrefix(got, want+".") || len(got) > 255 {
+			t.Errorf("PlanAddition placed %q, want %q followed by its extension", got, want)
+		}
+	}
+	m := media.Movie{
+		Show: media.Show{Name: strings.Repeat("a", 300), Year: "1997", ID: "754", Dir: dir},
+		File: eps[0],
+	}
+	p, err = media.PlanMovie(m, media.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = strings.Repeat("a", 255-len(" (1997) [tmdbid-754].mkv")) + " (1997) [tmdbid-754].mkv"
+	if got := filepath.Base(p.Ops[0].Dst); got != want {
+		t.Errorf("PlanMovie(%v) placed %q, want %q", m, got, want)
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2024 Matthew P. Dargan. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package media_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/matthewdargan/epify/media"
+)
+
+func TestSidecars(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		episodes []string
+		beside   []string
+		want     []string
+		wantErr  bool
+	}{
+		{
+			name:     "sidecars in episodes",
+			episodes: []string{"ep05.en.srt", "ep05.mkv", "ep06.mkv", "ep05.forced.en.ass", "ep06.mka"},
+			want: []string{
+				"Space Dandy S01E01.mkv", "Space Dandy S01E01.en.srt", "Space Dandy S01E01.forced.en.ass",
+				"Space Dandy S01E02.mkv", "Space Dandy S01E02.mka",
+			},
+		},
+		{
+			name:     "sidecars beside episodes",
+			episodes: []string{"ep1.mkv", "ep10.mkv"},
+			beside:   []string{"ep1.nfo", "ep10.en.srt", "ep11.en.srt"},
+			want: []string{
+				"Space Dandy S01E01.mkv", "Space Dandy S01E01.nfo",
+				"Space Dandy S01E02.mkv", "Space Dandy S01E02.en.srt",
+			},
+		},
+		{
+			name:     "sidecar without episode",
+			episodes: []string{"ep1.mkv", "ep2.srt"},
+			wantErr:  true,
+		},
+		{
+			name:     "only sidecars",
+			episodes: []string{"ep1.srt"},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			dir, showDir := setupShow(t, "Space Dandy (2014) [tvdbid-276083]")
+			s := media.Season{N: "1", ShowDir: showDir, Episodes: setupFiles(t, dir, tt.episodes...)}
+			setupFiles(t, dir, tt.beside...)
+			p, err := media.PlanSeason(s, media.Options{})
+			checkPlan(t, fmt.Sprintf("PlanSeason(%v)", s), p, err, tt.want, tt.wantErr)
+		})
+	}
+}